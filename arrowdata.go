@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/decimal128"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// arrowType maps a result column to an Arrow data type: NUMBER(p,s) to a
+// decimal, or to int64 when it has no decimal places and fits, other numbers
+// to double, dates to timestamps, RAW and BLOB to binary and the rest to string
+func arrowType(col Column) arrow.DataType {
+	switch {
+	case col.IsNumeric():
+		switch col.Type {
+		case "BFLOAT", "IBFLOAT", "BINARY_FLOAT":
+			return arrow.PrimitiveTypes.Float32
+		case "FLOAT", "BDOUBLE", "IBDOUBLE", "BINARY_DOUBLE":
+			return arrow.PrimitiveTypes.Float64
+		}
+		switch {
+		case col.Precision > 0 && col.Precision <= 18 && col.Scale == 0:
+			return arrow.PrimitiveTypes.Int64
+		case col.Precision > 0 && col.Precision <= 38 && col.Scale >= 0 && col.Scale <= col.Precision:
+			return &arrow.Decimal128Type{Precision: int32(col.Precision), Scale: int32(col.Scale)}
+		}
+		return arrow.PrimitiveTypes.Float64
+	case col.IsTime():
+		// Values with a time zone are instants, the others are wall clock times
+		if strings.Contains(col.Type, "TZ") {
+			return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+		}
+		return &arrow.TimestampType{Unit: arrow.Microsecond}
+	case col.IsBinary():
+		return arrow.BinaryTypes.Binary
+	}
+	return arrow.BinaryTypes.String
+}
+
+// arrowSchema builds the schema of a result. Repeated column names, as in
+// SELECT a.id, b.id, get a suffix, since readers look columns up by name.
+func arrowSchema(columns []Column) *arrow.Schema {
+	used := make(map[string]bool)
+	fields := make([]arrow.Field, len(columns))
+	for i, col := range columns {
+		name := col.Name
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", col.Name, n)
+		}
+		used[strings.ToLower(name)] = true
+		fields[i] = arrow.Field{Name: name, Type: arrowType(col), Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// appendArrowValue appends a database value to the builder of its column
+func appendArrowValue(b array.Builder, col Column, v interface{}) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+
+	var err error
+	switch b := b.(type) {
+	case *array.Int64Builder:
+		var n int64
+		switch v := v.(type) {
+		case int64:
+			n = v
+		case uint64:
+			if v > math.MaxInt64 {
+				return fmt.Errorf("column %s: %d does not fit into int64", col.Name, v)
+			}
+			n = int64(v)
+		default:
+			n, err = strconv.ParseInt(formatValue(v), 10, 64)
+		}
+		b.Append(n)
+	case *array.Float64Builder:
+		var f float64
+		f, err = arrowFloat(v)
+		b.Append(f)
+	case *array.Float32Builder:
+		var f float64
+		f, err = arrowFloat(v)
+		b.Append(float32(f))
+	case *array.Decimal128Builder:
+		dt := b.Type().(*arrow.Decimal128Type)
+		var n decimal128.Num
+		n, err = decimal128.FromString(arrowNumberText(v), dt.Precision, dt.Scale)
+		b.Append(n)
+	case *array.TimestampBuilder:
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("column %s: %v is not a date", col.Name, v)
+		}
+		if b.Type().(*arrow.TimestampType).TimeZone == "" {
+			// Keep the wall clock time of values without a time zone
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		}
+		var ts arrow.Timestamp
+		ts, err = arrow.TimestampFromTime(t, arrow.Microsecond)
+		b.Append(ts)
+	case *array.BinaryBuilder:
+		switch v := v.(type) {
+		case []byte:
+			b.Append(v)
+		default:
+			b.AppendString(formatValue(v))
+		}
+	case *array.StringBuilder:
+		b.Append(formatValue(v))
+	default:
+		return fmt.Errorf("column %s: unsupported type %s", col.Name, b.Type())
+	}
+
+	if err != nil {
+		return fmt.Errorf("column %s: %w", col.Name, err)
+	}
+	return nil
+}
+
+// arrowFloat converts a number of any representation to float64
+func arrowFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	}
+	return strconv.ParseFloat(formatValue(v), 64)
+}
+
+// arrowNumberText returns a number as decimal text
+func arrowNumberText(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return formatValue(v)
+}
+
+// recordBatcher collects rows into Arrow records of a fixed number of rows,
+// which are passed to write when they are full
+type recordBatcher struct {
+	builder *array.RecordBuilder
+	columns []Column
+	size    int
+	rows    int // rows of the current record
+	write   func(arrow.Record) error
+}
+
+func newRecordBatcher(schema *arrow.Schema, columns []Column, size int, write func(arrow.Record) error) *recordBatcher {
+	return &recordBatcher{
+		builder: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+		columns: columns,
+		size:    size,
+		write:   write,
+	}
+}
+
+// append adds a row, writing the record when it is full
+func (b *recordBatcher) append(row []interface{}) error {
+	for i, v := range row {
+		if err := appendArrowValue(b.builder.Field(i), b.columns[i], v); err != nil {
+			return err
+		}
+	}
+	b.rows++
+	if b.rows >= b.size {
+		return b.flush()
+	}
+	return nil
+}
+
+// flush writes the collected rows as a record
+func (b *recordBatcher) flush() error {
+	if b.rows == 0 {
+		return nil
+	}
+	record := b.builder.NewRecord()
+	defer record.Release()
+	b.rows = 0
+	return b.write(record)
+}
+
+func (b *recordBatcher) release() {
+	b.builder.Release()
+}
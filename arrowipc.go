@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+)
+
+// defaultArrowBatch is the number of rows of an Arrow record batch
+const defaultArrowBatch = 65536
+
+// arrowRecordWriter is implemented by the Arrow IPC stream and file writers
+type arrowRecordWriter interface {
+	Write(arrow.Record) error
+	Close() error
+}
+
+// arrowWriter writes a result in the Arrow IPC format: a stream to stdout or a
+// .arrows file, which readers consume batch by batch, or a Feather v2 file
+// with a footer for random access.
+type arrowWriter struct {
+	out     *outputFile
+	stream  bool
+	batch   int
+	writer  arrowRecordWriter
+	batches *recordBatcher
+	results int
+}
+
+func newArrowWriter(config *OutputConfig) (*arrowWriter, error) {
+	batch := config.ArrowBatch
+	if batch <= 0 {
+		batch = defaultArrowBatch
+	}
+	out, err := createOutputFile(config)
+	if err != nil {
+		return nil, err
+	}
+	// Stdout and .arrows files get the stream format
+	filename, _ := splitCompressionExt(config.Filename)
+	stream := filename == "" || strings.HasSuffix(strings.ToLower(filename), ".arrows")
+	return &arrowWriter{out: out, stream: stream, batch: batch}, nil
+}
+
+func (w *arrowWriter) BeginResult(res *Result) error {
+	// A stream or file has a single schema
+	if w.results > 0 {
+		return fmt.Errorf("an arrow file or stream holds a single result, write query %d to its own file", res.QueryIndex)
+	}
+	w.results++
+	return w.open(arrowSchema(res.Columns), res.Columns)
+}
+
+// open starts the stream or file with the schema of the result
+func (w *arrowWriter) open(schema *arrow.Schema, columns []Column) error {
+	if w.stream {
+		w.writer = ipc.NewWriter(w.out, ipc.WithSchema(schema))
+	} else {
+		file, err := ipc.NewFileWriter(w.out, ipc.WithSchema(schema))
+		if err != nil {
+			return err
+		}
+		w.writer = file
+	}
+	w.batches = newRecordBatcher(schema, columns, w.batch, w.write)
+	return nil
+}
+
+// write writes a record batch, a stream is flushed for readers waiting on it
+func (w *arrowWriter) write(record arrow.Record) error {
+	if err := w.writer.Write(record); err != nil {
+		return err
+	}
+	if w.stream {
+		return w.out.Flush()
+	}
+	return nil
+}
+
+func (w *arrowWriter) WriteRow(row []interface{}) error {
+	return w.batches.append(row)
+}
+
+func (w *arrowWriter) EndResult(res *Result) error {
+	return w.batches.flush()
+}
+
+func (w *arrowWriter) Size() int64 {
+	return w.out.Size()
+}
+
+func (w *arrowWriter) Close() error {
+	// A run without results writes an empty schema
+	if w.writer == nil {
+		if err := w.open(arrow.NewSchema(nil, nil), nil); err != nil {
+			w.out.Abort()
+			return fmt.Errorf("failed to save arrow file: %w", err)
+		}
+	}
+	w.batches.release()
+	if err := w.writer.Close(); err != nil {
+		w.out.Abort()
+		return fmt.Errorf("failed to save arrow file: %w", err)
+	}
+	return w.out.Close()
+}
+
+func (w *arrowWriter) Abort() {
+	if w.batches != nil {
+		w.batches.release()
+	}
+	w.out.Abort()
+}
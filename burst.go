@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// orderByRegex matches the first sort key of an ORDER BY clause
+var orderByRegex = regexp.MustCompile(`(?is)\border\s+by\s+((?:"[^"]+"|[\w$#]+)(?:\.(?:"[^"]+"|[\w$#]+))*)`)
+
+// isSortedBy reports whether the rows of a query come sorted by column,
+// i.e. the ORDER BY of the outer query starts with it. Sorts of subqueries and
+// analytic functions like ROW_NUMBER() OVER (ORDER BY ...) don't count.
+func isSortedBy(query, column string) bool {
+	matches := orderByRegex.FindAllStringSubmatch(outerQuery(query), -1)
+	if len(matches) == 0 {
+		return false
+	}
+	key := matches[len(matches)-1][1]
+	// Drop the table alias
+	if idx := strings.LastIndex(key, "."); idx != -1 {
+		key = key[idx+1:]
+	}
+	return strings.EqualFold(strings.Trim(key, `"`), column)
+}
+
+// outerQuery blanks out everything of a query that is not part of its outer
+// level: text in parentheses, string literals and comments
+func outerQuery(query string) string {
+	buf := []byte(query)
+	depth := 0
+	for i := 0; i < len(buf); i++ {
+		// end returns the position after the closing text, the end of the query if it is missing
+		end := func(closing string) int {
+			if idx := strings.Index(query[i+1:], closing); idx != -1 {
+				return i + 1 + idx + len(closing)
+			}
+			return len(buf)
+		}
+		skip := i + 1
+		switch {
+		case buf[i] == '\'':
+			skip = end("'")
+		case buf[i] == '"':
+			// Quoted names are kept, they may be the sort key
+			i = end(`"`) - 1
+			continue
+		case strings.HasPrefix(query[i:], "--"):
+			skip = end("\n")
+		case strings.HasPrefix(query[i:], "/*"):
+			skip = end("*/")
+		case buf[i] == '(':
+			depth++
+		case buf[i] == ')':
+			depth = max(depth-1, 0)
+		default:
+			if depth == 0 {
+				continue
+			}
+		}
+		for j := i; j < skip; j++ {
+			buf[j] = ' '
+		}
+		i = skip - 1
+	}
+	return string(buf)
+}
+
+// burstName appends the burst value of a partition to a result name
+func burstName(name, value string) string {
+	switch {
+	case value == "":
+		return name
+	case name == "":
+		return value
+	default:
+		return name + " - " + value
+	}
+}
+
+// partName returns the tab name of a result followed by its burst value
+func partName(info QueryInfo) string {
+	return burstName(info.TableName, info.BurstValue)
+}
+
+// burstWriter splits a result into one result per distinct value of a column.
+// Rows sorted by the column are streamed, one partition after another. Other
+// rows are spilled to a temporary file and written out partition by partition
+// when the result ends.
+type burstWriter struct {
+	out    ResultWriter
+	column string
+	sorted bool
+	index  int     // position of the burst column
+	res    *Result // the result being split
+	part   *Result // current partition of sorted rows
+	seen   map[string]bool
+
+	// Unsorted rows
+	spill  *os.File
+	writer *bufio.Writer
+	size   int64
+	parts  []*burstPart
+	byName map[string]*burstPart
+}
+
+// burstPart holds the positions of the spilled rows of a partition
+type burstPart struct {
+	value   string
+	offsets []int64
+	lengths []int
+}
+
+func newBurstWriter(out ResultWriter, column string, sorted bool) *burstWriter {
+	return &burstWriter{out: out, column: column, sorted: sorted}
+}
+
+func (w *burstWriter) BeginResult(res *Result) error {
+	w.index = -1
+	for i, col := range res.Columns {
+		if strings.EqualFold(col.Name, w.column) {
+			w.index = i
+			break
+		}
+	}
+	if w.index == -1 {
+		return fmt.Errorf("burst column %s not found in the result", w.column)
+	}
+
+	w.res = res
+	w.part = nil
+	w.seen = make(map[string]bool)
+	w.parts = nil
+	w.byName = make(map[string]*burstPart)
+
+	if !w.sorted && w.spill == nil {
+		spill, err := os.CreateTemp("", "gocl-burst-*")
+		if err != nil {
+			return err
+		}
+		w.spill = spill
+		w.writer = bufio.NewWriter(spill)
+	}
+	return nil
+}
+
+func (w *burstWriter) WriteRow(row []interface{}) error {
+	value := formatValue(row[w.index])
+
+	if w.sorted {
+		if w.part == nil || w.part.Info.BurstValue != value {
+			if w.seen[value] {
+				return fmt.Errorf("rows are not sorted by burst column %s: value %q appears again", w.column, value)
+			}
+			if err := w.endPart(); err != nil {
+				return err
+			}
+			w.seen[value] = true
+			w.part = w.newPart(value)
+			if err := w.out.BeginResult(w.part); err != nil {
+				return err
+			}
+		}
+		w.part.RowCount++
+		return w.out.WriteRow(row)
+	}
+
+	part := w.byName[value]
+	if part == nil {
+		part = &burstPart{value: value}
+		w.byName[value] = part
+		w.parts = append(w.parts, part)
+	}
+	n, err := writeSpillRow(w.writer, row)
+	if err != nil {
+		return fmt.Errorf("failed to spill row: %w", err)
+	}
+	part.offsets = append(part.offsets, w.size)
+	part.lengths = append(part.lengths, n)
+	w.size += int64(n)
+	return nil
+}
+
+func (w *burstWriter) EndResult(res *Result) error {
+	// A result without rows is written as is, so its outputs still get the header
+	if (w.sorted && w.part == nil) || (!w.sorted && len(w.parts) == 0) {
+		if err := w.out.BeginResult(res); err != nil {
+			return err
+		}
+		return w.out.EndResult(res)
+	}
+
+	if w.sorted {
+		return w.endPart()
+	}
+
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+	for _, p := range w.parts {
+		part := w.newPart(p.value)
+		if err := w.out.BeginResult(part); err != nil {
+			return err
+		}
+		for i, offset := range p.offsets {
+			row, err := readSpillRow(w.spill, offset, p.lengths[i], len(res.Columns))
+			if err != nil {
+				return fmt.Errorf("failed to read spilled row: %w", err)
+			}
+			part.RowCount++
+			if err := w.out.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		part.Info.Elapsed = time.Since(part.Info.Started)
+		if err := w.out.EndResult(part); err != nil {
+			return err
+		}
+	}
+
+	// Reuse the spill file for the next result
+	w.parts = nil
+	w.size = 0
+	if err := w.spill.Truncate(0); err != nil {
+		return err
+	}
+	_, err := w.spill.Seek(0, io.SeekStart)
+	w.writer.Reset(w.spill)
+	return err
+}
+
+// Close removes the spill file, the outputs are closed by their owner
+func (w *burstWriter) Close() error {
+	if w.spill == nil {
+		return nil
+	}
+	w.spill.Close()
+	return os.Remove(w.spill.Name())
+}
+
+func (w *burstWriter) Abort() {
+	w.Close()
+}
+
+// newPart starts the result of a partition
+func (w *burstWriter) newPart(value string) *Result {
+	part := *w.res
+	part.Info.BurstValue = value
+	part.RowCount = 0
+	return &part
+}
+
+// endPart completes the current partition of sorted rows
+func (w *burstWriter) endPart() error {
+	if w.part == nil {
+		return nil
+	}
+	w.part.Info.Elapsed = time.Since(w.part.Info.Started)
+	err := w.out.EndResult(w.part)
+	w.part = nil
+	return err
+}
+
+// Spilled value types
+const (
+	spillNull byte = iota
+	spillString
+	spillInt
+	spillFloat
+	spillTime
+	spillBytes
+	spillBool
+)
+
+// writeSpillRow encodes a row and returns the number of bytes written
+func writeSpillRow(w *bufio.Writer, row []interface{}) (int, error) {
+	buf, err := appendSpillRow(nil, row)
+	if err != nil {
+		return 0, err
+	}
+	return w.Write(buf)
+}
+
+// appendSpillRow appends the encoded row to buf.
+// Values of other types are stored as their text.
+func appendSpillRow(buf []byte, row []interface{}) ([]byte, error) {
+	for _, v := range row {
+		switch v := v.(type) {
+		case nil:
+			buf = append(buf, spillNull)
+		case string:
+			buf = append(buf, spillString)
+			buf = binary.AppendUvarint(buf, uint64(len(v)))
+			buf = append(buf, v...)
+		case int64:
+			buf = append(buf, spillInt)
+			buf = binary.AppendVarint(buf, v)
+		case float64:
+			buf = append(buf, spillFloat)
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		case time.Time:
+			data, err := v.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, spillTime)
+			buf = binary.AppendUvarint(buf, uint64(len(data)))
+			buf = append(buf, data...)
+		case []byte:
+			buf = append(buf, spillBytes)
+			buf = binary.AppendUvarint(buf, uint64(len(v)))
+			buf = append(buf, v...)
+		case bool:
+			buf = append(buf, spillBool)
+			if v {
+				buf = append(buf, 1)
+			} else {
+				buf = append(buf, 0)
+			}
+		default:
+			s := formatValue(v)
+			buf = append(buf, spillString)
+			buf = binary.AppendUvarint(buf, uint64(len(s)))
+			buf = append(buf, s...)
+		}
+	}
+	return buf, nil
+}
+
+// readSpillRow decodes a row written by writeSpillRow
+func readSpillRow(r io.ReaderAt, offset int64, length, columns int) ([]interface{}, error) {
+	buf := make([]byte, length)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return nil, err
+	}
+	return decodeSpillRow(buf, columns)
+}
+
+// decodeSpillRow decodes a row encoded by appendSpillRow
+func decodeSpillRow(buf []byte, columns int) ([]interface{}, error) {
+	// bytesValue reads a length-prefixed value
+	bytesValue := func() ([]byte, error) {
+		n, size := binary.Uvarint(buf)
+		if size <= 0 || uint64(len(buf)-size) < n {
+			return nil, io.ErrUnexpectedEOF
+		}
+		data := buf[size : size+int(n)]
+		buf = buf[size+int(n):]
+		return data, nil
+	}
+
+	row := make([]interface{}, columns)
+	for i := range row {
+		if len(buf) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		kind := buf[0]
+		buf = buf[1:]
+
+		switch kind {
+		case spillNull:
+		case spillString:
+			data, err := bytesValue()
+			if err != nil {
+				return nil, err
+			}
+			row[i] = string(data)
+		case spillInt:
+			v, size := binary.Varint(buf)
+			if size <= 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			row[i] = v
+			buf = buf[size:]
+		case spillFloat:
+			if len(buf) < 8 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			row[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf))
+			buf = buf[8:]
+		case spillTime:
+			data, err := bytesValue()
+			if err != nil {
+				return nil, err
+			}
+			var t time.Time
+			if err := t.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+			row[i] = t
+		case spillBytes:
+			data, err := bytesValue()
+			if err != nil {
+				return nil, err
+			}
+			row[i] = append([]byte(nil), data...)
+		case spillBool:
+			if len(buf) < 1 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			row[i] = buf[0] == 1
+			buf = buf[1:]
+		default:
+			return nil, fmt.Errorf("invalid value type %d", kind)
+		}
+	}
+	return row, nil
+}
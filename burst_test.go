@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestIsSortedBy(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT * FROM sales ORDER BY branch_code", true},
+		{"SELECT * FROM sales s ORDER BY s.BRANCH_CODE, day", true},
+		{`SELECT * FROM sales ORDER BY "BRANCH_CODE"`, true},
+		{"SELECT * FROM sales ORDER BY day, branch_code", false},
+		{"SELECT * FROM sales", false},
+		{"SELECT branch_code, ROW_NUMBER() OVER (ORDER BY branch_code) rn FROM sales", false},
+		{"SELECT branch_code, ROW_NUMBER() OVER (PARTITION BY region ORDER BY branch_code) rn FROM sales ORDER BY rn", false},
+		{"SELECT branch_code, ROW_NUMBER() OVER (ORDER BY day) rn FROM sales ORDER BY branch_code", true},
+		{"SELECT * FROM (SELECT * FROM sales ORDER BY branch_code)", false},
+		{"SELECT * FROM sales WHERE note <> ') ORDER BY branch_code' ORDER BY day", false},
+		{"SELECT * FROM sales -- ORDER BY branch_code\nORDER BY day", false},
+		{"SELECT * FROM sales /* (ORDER BY day */ ORDER BY branch_code", true},
+	}
+	for _, tt := range tests {
+		if got := isSortedBy(tt.query, "BRANCH_CODE"); got != tt.want {
+			t.Errorf("isSortedBy(%q) = %t, want %t", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Column describes a result set column
+type Column struct {
+	Name      string
+	Type      string // database type name reported by the driver, e.g. NUMBER, NCHAR, DATE
+	Precision int64
+	Scale     int64
+	Length    int64
+	Nullable  bool
+}
+
+// Driver type names grouped by kind. They are the names of the go-ora TNS
+// types, e.g. SB1 for BINARY_INTEGER.
+var (
+	numericTypes = map[string]bool{
+		"NUMBER": true, "FLOAT": true, "SB1": true, "VARNUM": true, "UINT": true,
+		"BFLOAT": true, "BDOUBLE": true, "IBFLOAT": true, "IBDOUBLE": true,
+		"BINARY_FLOAT": true, "BINARY_DOUBLE": true, "INTEGER": true,
+	}
+	timeTypes = map[string]bool{
+		"DATE": true, "OCIDATE": true, "TIMESTAMP": true, "TIMESTAMPTZ": true,
+		"TIMESTAMPDTY": true, "TIMESTAMPTZ_DTY": true, "TIMESTAMPLTZ_DTY": true,
+		"TIMESTAMPELTZ": true, "TIMETZ": true,
+	}
+	binaryTypes = map[string]bool{
+		"RAW": true, "LONGRAW": true, "VARRAW": true, "LONGVARRAW": true,
+		"OCIBLOBLOCATOR": true, "OCIFILELOCATOR": true, "BLOB": true, "BFILE": true,
+	}
+)
+
+// newColumns builds column descriptions from the driver column types
+func newColumns(types []*sql.ColumnType) []Column {
+	columns := make([]Column, len(types))
+	for i, ct := range types {
+		columns[i].Name = ct.Name()
+		columns[i].Type = strings.ToUpper(ct.DatabaseTypeName())
+		if precision, scale, ok := ct.DecimalSize(); ok {
+			columns[i].Precision = precision
+			columns[i].Scale = scale
+		}
+		if length, ok := ct.Length(); ok {
+			columns[i].Length = length
+		}
+		if nullable, ok := ct.Nullable(); ok {
+			columns[i].Nullable = nullable
+		}
+	}
+	return columns
+}
+
+// IsNumeric reports whether the column holds numbers
+func (c Column) IsNumeric() bool {
+	return numericTypes[c.Type]
+}
+
+// HasFloatingScale reports whether a NUMBER column has no fixed scale. FLOAT and
+// NUMBER without precision are described with scale -127, which the driver
+// reports as a byte above 127.
+func (c Column) HasFloatingScale() bool {
+	return c.Scale > 127
+}
+
+// IsTime reports whether the column holds dates or timestamps
+func (c Column) IsTime() bool {
+	return timeTypes[c.Type]
+}
+
+// IsBinary reports whether the column holds raw bytes
+func (c Column) IsBinary() bool {
+	return binaryTypes[c.Type]
+}
+
+// columnNames returns the names of the columns
+func columnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return names
+}
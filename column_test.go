@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestColumnIsNumeric(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want bool
+	}{
+		{"NUMBER", true},
+		{"FLOAT", true},
+		{"SB1", true},
+		{"VARNUM", true},
+		{"UINT", true},
+		{"BFLOAT", true},
+		{"IBDOUBLE", true},
+		{"NCHAR", false},
+		{"CHAR", false},
+		{"DATE", false},
+		{"RAW", false},
+	}
+	for _, tt := range tests {
+		if got := (Column{Type: tt.typ}).IsNumeric(); got != tt.want {
+			t.Errorf("Column{Type: %q}.IsNumeric() = %t, want %t", tt.typ, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression methods of outputs
+const (
+	CompressNone = ""
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+	CompressXZ   = "xz"
+)
+
+// compressionExtensions maps file extensions to compression methods
+var compressionExtensions = map[string]string{
+	".gz":   CompressGzip,
+	".gzip": CompressGzip,
+	".zst":  CompressZstd,
+	".zstd": CompressZstd,
+	".xz":   CompressXZ,
+}
+
+// xzDictSizes are the dictionary sizes of the xz presets 0-9
+var xzDictSizes = []int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+// splitCompressionExt returns a file name without its compression extension and the compression method
+func splitCompressionExt(filename string) (string, string) {
+	ext := filepath.Ext(filename)
+	if method, ok := compressionExtensions[strings.ToLower(ext)]; ok {
+		return strings.TrimSuffix(filename, ext), method
+	}
+	return filename, CompressNone
+}
+
+// outputCompression determines the compression method of an output
+func outputCompression(config *OutputConfig) string {
+	if config.Filename == "" {
+		return config.Compression
+	}
+	_, method := splitCompressionExt(config.Filename)
+	return method
+}
+
+// isCompressionMethod reports whether method is a supported compression method
+func isCompressionMethod(method string) bool {
+	switch method {
+	case CompressNone, CompressGzip, CompressZstd, CompressXZ:
+		return true
+	}
+	return false
+}
+
+// newCompressor wraps w in a compressing writer. Level 0 selects the default
+// level of the method: 1-9 for gzip and xz, 1-22 for zstd.
+func newCompressor(w io.Writer, method string, level int) (io.WriteCloser, error) {
+	switch method {
+	case CompressGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CompressZstd:
+		if level == 0 {
+			return zstd.NewWriter(w)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	case CompressXZ:
+		config := xz.WriterConfig{}
+		if level > 0 {
+			config.DictCap = xzDictSizes[min(level, len(xzDictSizes)-1)]
+		}
+		return config.NewWriter(w)
+	}
+	return nil, fmt.Errorf("unknown compression %q", method)
+}
+
+// Magic numbers of compressed input
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// decompressInput detects compressed input by its magic number and returns a decompressing reader
+func decompressInput(r io.Reader, debug bool) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(xzMagic))
+
+	method := CompressNone
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		method = CompressGzip
+	case bytes.HasPrefix(head, zstdMagic):
+		method = CompressZstd
+	case bytes.HasPrefix(head, xzMagic):
+		method = CompressXZ
+	}
+	if debug && method != CompressNone {
+		fmt.Fprintf(os.Stderr, "Input file compression: %s\n", method)
+	}
+
+	switch method {
+	case CompressGzip:
+		return gzip.NewReader(br)
+	case CompressZstd:
+		decoder, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case CompressXZ:
+		decoder, err := xz.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(decoder), nil
+	}
+	return io.NopCloser(br), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// confluenceWriter writes Confluence storage format, the XHTML that the
+// Confluence editors and the REST API store pages in. Unlike Jira wiki
+// markup it is pasted into the source editor or sent as a page body as is.
+type confluenceWriter struct {
+	out        *outputFile
+	withHeader bool
+	nullStyle  string
+	withSQL    bool
+	columns    []Column
+}
+
+func newConfluenceWriter(config *OutputConfig, withHeader bool) (*confluenceWriter, error) {
+	out, err := createOutputFile(config)
+	if err != nil {
+		return nil, err
+	}
+	return &confluenceWriter{out: out, withHeader: withHeader, nullStyle: config.NullStyle, withSQL: config.ConfluenceSQL}, nil
+}
+
+func (w *confluenceWriter) BeginResult(res *Result) error {
+	w.columns = res.Columns
+
+	if title := resultHeading(res.Info); title != "" {
+		fmt.Fprintf(w.out, "<h1>%s</h1>\n", escapeConfluence(title))
+	}
+	if res.Info.Description != "" {
+		fmt.Fprintf(w.out, "<p>%s</p>\n", escapeConfluence(res.Info.Description))
+	}
+	if w.withSQL {
+		sqlText := res.Info.SQL
+		if sqlText == "" {
+			sqlText = res.Info.Query
+		}
+		writeConfluenceCode(w.out, "sql", sqlText)
+	}
+
+	fmt.Fprintln(w.out, "<table>")
+	fmt.Fprintln(w.out, "<tbody>")
+	if w.withHeader && !res.Info.NoHeader {
+		io.WriteString(w.out, "<tr>")
+		for _, col := range res.Columns {
+			fmt.Fprintf(w.out, "<th>%s</th>", escapeConfluence(col.Name))
+		}
+		_, err := io.WriteString(w.out, "</tr>\n")
+		return err
+	}
+	return nil
+}
+
+func (w *confluenceWriter) WriteRow(row []interface{}) error {
+	io.WriteString(w.out, "<tr>")
+	for i, cell := range row {
+		align := ""
+		if w.columns[i].IsNumeric() {
+			align = ` style="text-align: right;"`
+		}
+		switch {
+		case cell == nil && w.nullStyle == NullEmpty:
+			io.WriteString(w.out, "<td></td>")
+		case cell == nil && w.nullStyle == NullStyled:
+			io.WriteString(w.out, `<td><span style="color: rgb(153,153,153);"><em>NULL</em></span></td>`)
+		default:
+			fmt.Fprintf(w.out, "<td%s>%s</td>", align, escapeConfluence(formatValue(cell)))
+		}
+	}
+	_, err := io.WriteString(w.out, "</tr>\n")
+	return err
+}
+
+func (w *confluenceWriter) EndResult(res *Result) error {
+	fmt.Fprintln(w.out, "</tbody>")
+	fmt.Fprintln(w.out, "</table>")
+	return w.out.Flush()
+}
+
+func (w *confluenceWriter) Size() int64 {
+	return w.out.Size()
+}
+
+func (w *confluenceWriter) Close() error {
+	return w.out.Close()
+}
+
+func (w *confluenceWriter) Abort() {
+	w.out.Abort()
+}
+
+// escapeConfluence escapes text for storage format, turning line breaks into <br />
+func escapeConfluence(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("<br />")
+		}
+		xml.EscapeText(&b, []byte(line))
+	}
+	return b.String()
+}
+
+// writeConfluenceCode writes a code macro. Its body is CDATA, which cannot
+// contain "]]>", so that is split over two sections.
+func writeConfluenceCode(w io.Writer, language, code string) {
+	fmt.Fprintln(w, `<ac:structured-macro ac:name="code">`)
+	fmt.Fprintf(w, "<ac:parameter ac:name=\"language\">%s</ac:parameter>\n", language)
+	fmt.Fprintf(w, "<ac:plain-text-body><![CDATA[%s]]></ac:plain-text-body>\n",
+		strings.ReplaceAll(code, "]]>", "]]]]><![CDATA[>"))
+	fmt.Fprintln(w, "</ac:structured-macro>")
+}
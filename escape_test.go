@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestEscapeJIRA(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"a|b", `a\|b`},
+		{"{code}", `\{code\}`},
+		{"[link]!img!", `\[link\]\!img\!`},
+		{`a\`, "a&#92;"},
+		{`a\|b`, `a&#92;\|b`},
+		{"one\r\ntwo\nthree\rfour", `one\\two\\three\\four`},
+	}
+	for _, tt := range tests {
+		if got := escapeJIRA(tt.in); got != tt.want {
+			t.Errorf("escapeJIRA(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeHTML(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"Tom & Jerry", "Tom &amp; Jerry"},
+		{`say "hi" & 'bye'`, "say &#34;hi&#34; &amp; &#39;bye&#39;"},
+		{"one\r\ntwo\nthree\rfour", "one<br>two<br>three<br>four"},
+	}
+	for _, tt := range tests {
+		if got := escapeHTML(tt.in); got != tt.want {
+			t.Errorf("escapeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// placeholderRegex matches {name} and {name:spec} in output file names
+var placeholderRegex = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)(?::([^{}]*))?\}`)
+
+// widthRegex matches the spec of {index:02}
+var widthRegex = regexp.MustCompile(`^0?[1-9][0-9]?$`)
+
+// fileNameReplacer replaces characters that are not allowed in file names
+var fileNameReplacer = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_",
+	"\"", "_", "<", "_", ">", "_", "|", "_",
+	"\r", "_", "\n", "_", "\t", "_",
+)
+
+// isFilenameTemplate reports whether an output name contains placeholders
+func isFilenameTemplate(filename string) bool {
+	return placeholderRegex.MatchString(filename)
+}
+
+// runInfo describes a run: the values of output file name placeholders and
+// the details listed on the Run info sheet of Excel workbooks
+type runInfo struct {
+	Started   time.Time // start of the run
+	Input     string    // script file, empty for -q and stdin
+	User      string
+	Database  string
+	Server    string
+	Container string
+	NLS       []string
+	Vars      map[string]string
+}
+
+// newRunInfo collects the values that are the same for the whole run
+func newRunInfo(params *AppParams, connStr string) runInfo {
+	run := runInfo{
+		Started:   time.Now(),
+		Input:     params.InputFile,
+		User:      params.ConnParams.User,
+		Database:  params.ConnParams.Service,
+		Server:    params.ConnParams.Server,
+		Container: params.Session.Container,
+		NLS:       params.Session.NLS,
+		Vars:      params.Params,
+	}
+	if run.Server != "" && params.ConnParams.Port != "" {
+		run.Server += ":" + params.ConnParams.Port
+	}
+
+	// Take the names from the connection string if it was given instead
+	if u, err := url.Parse(connStr); err == nil {
+		if run.User == "" && u.User != nil {
+			run.User = u.User.Username()
+		}
+		if run.Database == "" {
+			run.Database = strings.TrimPrefix(u.Path, "/")
+		}
+		if run.Server == "" {
+			run.Server = u.Host
+		}
+	}
+
+	return run
+}
+
+// expandFilename replaces the placeholders of an output file name template:
+//
+//	{index}, {index:02}   query number, optionally zero-padded to the given width
+//	{tab}                 tab name of the query
+//	{burst}               value of the burst column
+//	{date}, {time}        start of the run as 2006-01-02 and 150405
+//	{datetime}            start of the run as 20060102_150405
+//	{user}, {database}    connection user and service name
+//	{name}                value of the variable set with -v name=value
+func expandFilename(template string, run runInfo, queryIndex int, info QueryInfo) (string, error) {
+	var expandErr error
+	filename := placeholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		matches := placeholderRegex.FindStringSubmatch(placeholder)
+		name, spec := matches[1], matches[2]
+
+		if name == "index" {
+			if spec == "" {
+				return fmt.Sprint(queryIndex)
+			}
+			if !widthRegex.MatchString(spec) {
+				expandErr = fmt.Errorf("invalid width in %s", placeholder)
+				return placeholder
+			}
+			width, _ := strconv.Atoi(spec)
+			return fmt.Sprintf("%0*d", width, queryIndex)
+		}
+		if spec != "" {
+			expandErr = fmt.Errorf("placeholder %s does not take a format", placeholder)
+			return placeholder
+		}
+
+		var value string
+		switch name {
+		case "tab":
+			value = info.TableName
+			if value == "" {
+				value = fmt.Sprintf("Results%d", queryIndex)
+			}
+		case "burst":
+			value = info.BurstValue
+		case "date":
+			value = run.Started.Format("2006-01-02")
+		case "time":
+			value = run.Started.Format("150405")
+		case "datetime":
+			value = run.Started.Format("20060102_150405")
+		case "user":
+			value = run.User
+		case "database":
+			value = run.Database
+		default:
+			v, ok := run.Vars[name]
+			if !ok {
+				expandErr = fmt.Errorf("unknown placeholder %s", placeholder)
+				return placeholder
+			}
+			value = v
+		}
+
+		// Values must not add directories or invalid characters to the name
+		return fileNameReplacer.Replace(value)
+	})
+
+	if expandErr != nil {
+		return "", fmt.Errorf("output %s: %w", template, expandErr)
+	}
+	return filename, nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+var htmlLineBreakReplacer = strings.NewReplacer("\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// escapeHTML escapes a value for HTML text, keeping line breaks
+func escapeHTML(s string) string {
+	return htmlLineBreakReplacer.Replace(html.EscapeString(s))
+}
+
+// formatRowCount returns "1 row" or "N rows"
+func formatRowCount(n int) string {
+	if n == 1 {
+		return "1 row"
+	}
+	return fmt.Sprintf("%d rows", n)
+}
+
+// resultHeading returns the title or table name of a result, empty if it has neither
+func resultHeading(queryInfo QueryInfo) string {
+	if queryInfo.Title != "" {
+		return burstName(queryInfo.Title, queryInfo.BurstValue)
+	}
+	return partName(queryInfo)
+}
+
+// resultTitle returns the name of a result shown in headings
+func resultTitle(queryIndex int, queryInfo QueryInfo) string {
+	if heading := resultHeading(queryInfo); heading != "" {
+		return heading
+	}
+	return fmt.Sprintf("Query %d", queryIndex)
+}
+
+const htmlReportHeader = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <meta name="generator" content="gocl %s">
+    <title>Query Results</title>
+    <style>
+        body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; font-size: 14px; color: #222; margin: 0; background: #f6f7f9; }
+        header { background: #24364b; color: #fff; padding: 16px 32px; }
+        header h1 { margin: 0; font-size: 1.6em; font-weight: 600; }
+        header .generated { margin: 4px 0 0; color: #c8d3df; font-size: 0.9em; }
+        main, nav { margin: 0 32px; }
+        nav { background: #fff; border: 1px solid #dde2e8; border-radius: 6px; padding: 8px 24px; margin-top: 24px; }
+        nav h2 { font-size: 1.1em; margin: 8px 0; }
+        nav ol { margin: 0 0 8px; padding-left: 24px; }
+        nav li { margin: 2px 0; }
+        nav .count { color: #777; }
+        a { color: #1f6fb2; text-decoration: none; }
+        a:hover { text-decoration: underline; }
+        section.result { background: #fff; border: 1px solid #dde2e8; border-radius: 6px; padding: 8px 24px 24px; margin: 24px 0; }
+        section.result h2 { font-size: 1.3em; margin: 12px 0; }
+        section.result p.description { margin: 0 0 12px; }
+        details.meta { margin: 0 0 12px; color: #555; }
+        details.meta summary { cursor: pointer; }
+        details.meta dl { display: grid; grid-template-columns: max-content auto; gap: 2px 16px; margin: 8px 0; }
+        details.meta dt { font-weight: 600; }
+        details.meta dd { margin: 0; }
+        pre.sql { background: #f3f5f7; border: 1px solid #e1e5ea; border-radius: 4px; padding: 8px 12px; overflow-x: auto; white-space: pre-wrap; }
+        input.filter { margin: 0 0 8px; padding: 6px 8px; width: 280px; max-width: 100%%; border: 1px solid #c5ccd4; border-radius: 4px; }
+        .table-wrap { overflow-x: auto; }
+        table { border-collapse: collapse; }
+        th, td { border: 1px solid #dde2e8; padding: 6px 10px; text-align: left; vertical-align: top; }
+        th { background-color: #eef1f4; position: sticky; top: 0; cursor: pointer; user-select: none; white-space: nowrap; }
+        th.number, td.number { text-align: right; }
+        th[aria-sort="ascending"]::after { content: " \25B2"; }
+        th[aria-sort="descending"]::after { content: " \25BC"; }
+        tbody tr:nth-child(even) { background: #fafbfc; }
+        tbody tr:hover { background: #eef5fc; }
+        td.null { color: #999; font-style: italic; }
+        @media print {
+            body { background: #fff; font-size: 10pt; }
+            header { background: none; color: #000; padding: 0 0 8pt; border-bottom: 1pt solid #000; }
+            header .generated { color: #444; }
+            main, nav { margin: 0; }
+            nav, section.result { border: none; padding: 0; }
+            section.result { page-break-before: always; }
+            input.filter, details.meta summary { display: none; }
+            .table-wrap { overflow: visible; }
+            th { position: static; background: #eee; }
+            thead { display: table-header-group; }
+            tr { page-break-inside: avoid; }
+            th[aria-sort]::after { content: none; }
+            a { color: #000; }
+        }
+    </style>
+</head>
+<body>
+<header>
+    <h1>Query Results</h1>
+    <p class="generated">Generated %s by gocl</p>
+</header>
+<nav id="toc">
+    <h2>Contents</h2>
+    <ol>
+`
+
+const htmlReportMiddle = `    </ol>
+</nav>
+<main>
+`
+
+const htmlReportFooter = `</main>
+<script>
+(function () {
+    function cellValue(row, index) {
+        var cell = row.cells[index];
+        return cell && !cell.classList.contains("null") ? cell.textContent : "";
+    }
+
+    function compare(a, b, numeric) {
+        if (a === "" || b === "") {
+            return (a === "") - (b === "");
+        }
+        if (numeric) {
+            return parseFloat(a) - parseFloat(b);
+        }
+        return a.localeCompare(b, undefined, { numeric: true, sensitivity: "base" });
+    }
+
+    document.querySelectorAll("section.result").forEach(function (section) {
+        var table = section.querySelector("table.data");
+        var filter = section.querySelector("input.filter");
+        if (!table) {
+            return;
+        }
+        var tbody = table.tBodies[0];
+
+        table.querySelectorAll("thead th").forEach(function (th, index) {
+            th.addEventListener("click", function () {
+                var ascending = th.getAttribute("aria-sort") !== "ascending";
+                table.querySelectorAll("thead th").forEach(function (other) {
+                    other.removeAttribute("aria-sort");
+                });
+                th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+
+                var numeric = th.classList.contains("number");
+                var rows = Array.prototype.slice.call(tbody.rows);
+                rows.sort(function (a, b) {
+                    var result = compare(cellValue(a, index), cellValue(b, index), numeric);
+                    return ascending ? result : -result;
+                });
+                rows.forEach(function (row) {
+                    tbody.appendChild(row);
+                });
+            });
+        });
+
+        if (filter) {
+            filter.addEventListener("input", function () {
+                var text = filter.value.toLowerCase();
+                Array.prototype.forEach.call(tbody.rows, function (row) {
+                    row.style.display = row.textContent.toLowerCase().indexOf(text) === -1 ? "none" : "";
+                });
+            });
+        }
+    });
+
+    // Show query details on paper
+    window.addEventListener("beforeprint", function () {
+        document.querySelectorAll("details.meta").forEach(function (details) {
+            details.open = true;
+        });
+    });
+})();
+</script>
+</body>
+</html>
+`
+
+// htmlWriter writes an HTML report. The table of contents precedes all results
+// and every section starts with the row count, so finished sections are spooled
+// to a temporary file and the document is assembled on Close.
+type htmlWriter struct {
+	out        *outputFile
+	withHeader bool
+	nullStyle  string
+	generated  time.Time
+	toc        strings.Builder
+	sections   *spoolFile // finished sections
+	count      int        // number of the section, partitions of a query share its index
+	rows       *spoolFile // table rows of the current result
+	columns    []Column
+}
+
+func newHTMLWriter(config *OutputConfig, withHeader bool) (*htmlWriter, error) {
+	sections, err := newSpoolFile()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := newSpoolFile()
+	if err != nil {
+		sections.Close()
+		return nil, err
+	}
+	out, err := createOutputFile(config)
+	if err != nil {
+		sections.Close()
+		rows.Close()
+		return nil, err
+	}
+
+	return &htmlWriter{
+		out:        out,
+		withHeader: withHeader,
+		nullStyle:  config.NullStyle,
+		generated:  time.Now(),
+		sections:   sections,
+		rows:       rows,
+	}, nil
+}
+
+func (w *htmlWriter) BeginResult(res *Result) error {
+	w.columns = res.Columns
+	return w.rows.Reset()
+}
+
+func (w *htmlWriter) WriteRow(row []interface{}) error {
+	writeHTMLRow(w.rows, w.columns, row, w.nullStyle)
+	return nil
+}
+
+func (w *htmlWriter) EndResult(res *Result) error {
+	w.count++
+	writeHTMLTOCEntry(&w.toc, w.count, res)
+	writeHTMLSectionStart(w.sections, w.count, res, w.withHeader && !res.Info.NoHeader)
+	if err := w.rows.CopyTo(w.sections); err != nil {
+		return err
+	}
+	writeHTMLSectionEnd(w.sections)
+	return nil
+}
+
+// Size returns the size of the report so far, without the page template
+func (w *htmlWriter) Size() int64 {
+	return int64(w.toc.Len()) + w.sections.Size() + w.rows.Size()
+}
+
+func (w *htmlWriter) Close() error {
+	defer w.rows.Close()
+	defer w.sections.Close()
+
+	// A report that could not be written completely leaves the previous file in place
+	_, err := fmt.Fprintf(w.out, htmlReportHeader, Version, w.generated.Format("2006-01-02 15:04:05"))
+	if err == nil {
+		_, err = io.WriteString(w.out, w.toc.String())
+	}
+	if err == nil {
+		_, err = io.WriteString(w.out, htmlReportMiddle)
+	}
+	if err == nil {
+		err = w.sections.CopyTo(w.out)
+	}
+	if err == nil {
+		_, err = io.WriteString(w.out, htmlReportFooter)
+	}
+	if err != nil {
+		w.out.Abort()
+		return fmt.Errorf("failed to save HTML report: %w", err)
+	}
+
+	return w.out.Close()
+}
+
+func (w *htmlWriter) Abort() {
+	w.rows.Close()
+	w.sections.Close()
+	w.out.Abort()
+}
+
+func writeHTMLTOCEntry(writer io.Writer, section int, res *Result) {
+	fmt.Fprintf(writer, "        <li><a href=\"#result-%d\">%s</a> <span class=\"count\">(%s)</span></li>\n",
+		section, escapeHTML(resultTitle(res.QueryIndex, res.Info)), formatRowCount(res.RowCount))
+}
+
+// writeHTMLSectionStart writes the section heading, query details and table header
+func writeHTMLSectionStart(writer io.Writer, section int, res *Result, withHeader bool) {
+	info := res.Info
+
+	fmt.Fprintf(writer, "<section class=\"result\" id=\"result-%d\">\n", section)
+	fmt.Fprintf(writer, "    <h2>%s</h2>\n", escapeHTML(resultTitle(res.QueryIndex, info)))
+	if info.Description != "" {
+		fmt.Fprintf(writer, "    <p class=\"description\">%s</p>\n", escapeHTML(info.Description))
+	}
+
+	// Query details
+	fmt.Fprintln(writer, "    <details class=\"meta\">")
+	fmt.Fprintln(writer, "        <summary>Query details</summary>")
+	fmt.Fprintln(writer, "        <dl>")
+	fmt.Fprintf(writer, "            <dt>Rows</dt><dd>%d</dd>\n", res.RowCount)
+	fmt.Fprintf(writer, "            <dt>Elapsed</dt><dd>%s</dd>\n", info.Elapsed.Round(time.Millisecond))
+	if !info.Started.IsZero() {
+		fmt.Fprintf(writer, "            <dt>Executed at</dt><dd>%s</dd>\n", info.Started.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintln(writer, "        </dl>")
+	sqlText := info.SQL
+	if sqlText == "" {
+		sqlText = info.Query
+	}
+	fmt.Fprintf(writer, "        <pre class=\"sql\"><code>%s</code></pre>\n", html.EscapeString(sqlText))
+	fmt.Fprintln(writer, "    </details>")
+
+	fmt.Fprintln(writer, "    <input type=\"search\" class=\"filter\" placeholder=\"Filter rows\" aria-label=\"Filter rows\">")
+
+	fmt.Fprintln(writer, "    <div class=\"table-wrap\">")
+	fmt.Fprintln(writer, "    <table class=\"data\">")
+
+	if withHeader {
+		fmt.Fprintln(writer, "        <thead>")
+		fmt.Fprintln(writer, "            <tr>")
+		for _, col := range res.Columns {
+			if col.IsNumeric() {
+				fmt.Fprintf(writer, "                <th class=\"number\">%s</th>\n", escapeHTML(col.Name))
+			} else {
+				fmt.Fprintf(writer, "                <th>%s</th>\n", escapeHTML(col.Name))
+			}
+		}
+		fmt.Fprintln(writer, "            </tr>")
+		fmt.Fprintln(writer, "        </thead>")
+	}
+
+	fmt.Fprintln(writer, "        <tbody>")
+}
+
+func writeHTMLRow(writer io.Writer, columns []Column, row []interface{}, nullStyle string) {
+	fmt.Fprintln(writer, "            <tr>")
+	for i, cell := range row {
+		class := ""
+		if columns[i].IsNumeric() {
+			class = " class=\"number\""
+		}
+		switch {
+		case cell == nil && nullStyle == NullEmpty:
+			fmt.Fprintf(writer, "                <td%s></td>\n", class)
+		case cell == nil && nullStyle == NullStyled:
+			fmt.Fprintln(writer, "                <td class=\"null\">NULL</td>")
+		default:
+			fmt.Fprintf(writer, "                <td%s>%s</td>\n", class, escapeHTML(formatValue(cell)))
+		}
+	}
+	fmt.Fprintln(writer, "            </tr>")
+}
+
+func writeHTMLSectionEnd(writer io.Writer) {
+	fmt.Fprintln(writer, "        </tbody>")
+	fmt.Fprintln(writer, "    </table>")
+	fmt.Fprintln(writer, "    </div>")
+	fmt.Fprintln(writer, "</section>")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// readScriptQuery passes the lines of a script without separators to a scriptQuery
+func readScriptQuery(t *testing.T, script string) QueryInfo {
+	t.Helper()
+	var query scriptQuery
+	for _, line := range strings.Split(script, "\n") {
+		query.addLine(line)
+	}
+	info, err := query.info()
+	if err != nil {
+		t.Fatalf("%q: %v", script, err)
+	}
+	return info
+}
+
+func TestScriptQueryDirectives(t *testing.T) {
+	info := readScriptQuery(t, "-- output=orders.csv\n-- tab=Orders\nSELECT * FROM orders")
+	if len(info.Outputs) != 1 || info.Outputs[0] != "orders.csv" || info.TableName != "Orders" {
+		t.Errorf("directives before the query: got outputs %v and tab %q", info.Outputs, info.TableName)
+	}
+
+	// Comment lines within the query are no directives, even if they look like one
+	info = readScriptQuery(t, strings.Join([]string{
+		"-- tab=Orders",
+		"SELECT *",
+		"  FROM orders",
+		" WHERE region = 'EU'",
+		"--   AND status = 'NEW'",
+		"-- tab=Other",
+		"   AND amount > 0",
+	}, "\n"))
+	if info.TableName != "Orders" {
+		t.Errorf("tab = %q, want the directive before the query", info.TableName)
+	}
+	if strings.Contains(info.Query, "--") || !strings.Contains(info.Query, "AND amount > 0") {
+		t.Errorf("query = %q, want the query lines without the comments", info.Query)
+	}
+}
+
+func TestScriptQueryUnknownDirective(t *testing.T) {
+	var query scriptQuery
+	query.addLine("-- colour=red")
+	query.addLine("SELECT * FROM dual")
+	if _, err := query.info(); err == nil {
+		t.Error("unknown directive before the query was accepted")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var markdownReplacer = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	"\\", "\\\\",
+	"|", "\\|",
+	"\r\n", "<br>",
+	"\n", "<br>",
+	"\r", "<br>",
+)
+
+// escapeMarkdownCell makes a value safe to put into a GitHub-flavored Markdown table cell
+func escapeMarkdownCell(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+// markdownWriter writes GitHub-flavored Markdown tables, one section per result
+type markdownWriter struct {
+	out        *outputFile
+	withHeader bool
+}
+
+func newMarkdownWriter(config *OutputConfig, withHeader bool) (*markdownWriter, error) {
+	out, err := createOutputFile(config)
+	if err != nil {
+		return nil, err
+	}
+	return &markdownWriter{out: out, withHeader: withHeader}, nil
+}
+
+func (w *markdownWriter) BeginResult(res *Result) error {
+	w.out.separateSection(w.out)
+
+	// Every result is a separate section
+	fmt.Fprintf(w.out, "## %s\n\n", escapeMarkdownCell(resultTitle(res.QueryIndex, res.Info)))
+	if res.Info.Description != "" {
+		fmt.Fprintf(w.out, "%s\n\n", escapeMarkdownCell(res.Info.Description))
+	}
+
+	// Markdown tables always have a header row, leave it blank if headers are disabled
+	fmt.Fprint(w.out, "|")
+	for _, col := range res.Columns {
+		name := ""
+		if w.withHeader && !res.Info.NoHeader {
+			name = escapeMarkdownCell(col.Name)
+		}
+		fmt.Fprintf(w.out, " %s |", name)
+	}
+	fmt.Fprintln(w.out)
+
+	// Alignment row: numbers to the right
+	fmt.Fprint(w.out, "|")
+	for _, col := range res.Columns {
+		if col.IsNumeric() {
+			fmt.Fprint(w.out, " ---: |")
+		} else {
+			fmt.Fprint(w.out, " --- |")
+		}
+	}
+	_, err := fmt.Fprintln(w.out)
+	return err
+}
+
+func (w *markdownWriter) WriteRow(row []interface{}) error {
+	fmt.Fprint(w.out, "|")
+	for _, cell := range row {
+		fmt.Fprintf(w.out, " %s |", escapeMarkdownCell(formatValue(cell)))
+	}
+	_, err := fmt.Fprintln(w.out)
+	return err
+}
+
+func (w *markdownWriter) EndResult(res *Result) error {
+	return w.out.Flush()
+}
+
+func (w *markdownWriter) Size() int64 {
+	return w.out.Size()
+}
+
+func (w *markdownWriter) Close() error {
+	return w.out.Close()
+}
+
+func (w *markdownWriter) Abort() {
+	w.out.Abort()
+}
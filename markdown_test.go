@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestEscapeMarkdownCell(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"a|b", `a\|b`},
+		{`a\`, `a\\`},
+		{`a|b\|c`, `a\|b\\\|c`},
+		{"<b> & </b>", "&lt;b&gt; &amp; &lt;/b&gt;"},
+		{"one\r\ntwo\nthree\rfour", "one<br>two<br>three<br>four"},
+	}
+	for _, tt := range tests {
+		if got := escapeMarkdownCell(tt.in); got != tt.want {
+			t.Errorf("escapeMarkdownCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const odsMimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+const odsContentHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:number="urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0" xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0" office:version="1.2">
+<office:automatic-styles>
+<number:date-style style:name="N1"><number:year number:style="long"/><number:text>-</number:text><number:month number:style="long"/><number:text>-</number:text><number:day number:style="long"/></number:date-style>
+<number:date-style style:name="N2"><number:year number:style="long"/><number:text>-</number:text><number:month number:style="long"/><number:text>-</number:text><number:day number:style="long"/><number:text> </number:text><number:hours number:style="long"/><number:text>:</number:text><number:minutes number:style="long"/><number:text>:</number:text><number:seconds number:style="long"/></number:date-style>
+<style:style style:name="header" style:family="table-cell"><style:text-properties fo:font-weight="bold"/></style:style>
+<style:style style:name="date" style:family="table-cell" style:data-style-name="N1"/>
+<style:style style:name="datetime" style:family="table-cell" style:data-style-name="N2"/>
+</office:automatic-styles>
+<office:body>
+<office:spreadsheet>
+`
+
+const odsContentFooter = `</office:spreadsheet>
+</office:body>
+</office:document-content>
+`
+
+const odsManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">
+<manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="application/vnd.oasis.opendocument.spreadsheet"/>
+<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+<manifest:file-entry manifest:full-path="meta.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+
+const odsMeta = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0" office:version="1.2">
+<office:meta><meta:generator>gocl %s</meta:generator></office:meta>
+</office:document-meta>
+`
+
+// odsWriter writes an OpenDocument spreadsheet with one table per result.
+// Rows are streamed to the compressed content.xml member of the archive,
+// the other members are added on Close.
+type odsWriter struct {
+	out        *outputFile
+	zip        *zip.Writer
+	content    io.Writer
+	withHeader bool
+	names      sheetNames
+	columns    []Column
+	tables     int
+}
+
+func newODSWriter(config *OutputConfig, withHeader bool) (*odsWriter, error) {
+	out, err := createOutputFile(config)
+	if err != nil {
+		return nil, err
+	}
+	w := &odsWriter{out: out, zip: zip.NewWriter(out), withHeader: withHeader, names: make(sheetNames)}
+
+	// The mime type comes first and uncompressed, so the file type can be detected
+	mimetype, err := w.zip.CreateRaw(&zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE([]byte(odsMimeType)),
+		CompressedSize64:   uint64(len(odsMimeType)),
+		UncompressedSize64: uint64(len(odsMimeType)),
+	})
+	if err == nil {
+		_, err = io.WriteString(mimetype, odsMimeType)
+	}
+	if err == nil {
+		w.content, err = w.zip.Create("content.xml")
+	}
+	if err == nil {
+		_, err = io.WriteString(w.content, odsContentHeader)
+	}
+	if err != nil {
+		out.Abort()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *odsWriter) BeginResult(res *Result) error {
+	w.columns = res.Columns
+	w.tables++
+
+	name := partName(res.Info)
+	if name == "" {
+		name = fmt.Sprintf("Results%d", res.QueryIndex)
+	}
+	fmt.Fprintf(w.content, "<table:table table:name=\"%s\">\n", escapeXMLAttr(w.names.unique(sanitizeSheetName(name))))
+	fmt.Fprintf(w.content, "<table:table-column table:number-columns-repeated=\"%d\"/>\n", len(res.Columns))
+
+	if w.withHeader && !res.Info.NoHeader {
+		io.WriteString(w.content, "<table:table-row>")
+		for _, col := range res.Columns {
+			io.WriteString(w.content, `<table:table-cell table:style-name="header" office:value-type="string">`)
+			writeODSText(w.content, col.Name)
+			io.WriteString(w.content, "</table:table-cell>")
+		}
+		_, err := io.WriteString(w.content, "</table:table-row>\n")
+		return err
+	}
+	return nil
+}
+
+func (w *odsWriter) WriteRow(row []interface{}) error {
+	io.WriteString(w.content, "<table:table-row>")
+	for i, v := range row {
+		writeODSCell(w.content, w.columns[i], v)
+	}
+	_, err := io.WriteString(w.content, "</table:table-row>\n")
+	return err
+}
+
+func (w *odsWriter) EndResult(res *Result) error {
+	_, err := io.WriteString(w.content, "</table:table>\n")
+	return err
+}
+
+func (w *odsWriter) Close() error {
+	// A spreadsheet needs at least one table
+	if w.tables == 0 {
+		io.WriteString(w.content, "<table:table table:name=\"Sheet1\"><table:table-column/><table:table-row><table:table-cell/></table:table-row></table:table>\n")
+	}
+	_, err := io.WriteString(w.content, odsContentFooter)
+
+	members := []struct{ name, data string }{
+		{"meta.xml", fmt.Sprintf(odsMeta, Version)},
+		{"META-INF/manifest.xml", odsManifest},
+	}
+	for _, m := range members {
+		if err != nil {
+			break
+		}
+		var member io.Writer
+		if member, err = w.zip.Create(m.name); err == nil {
+			_, err = io.WriteString(member, m.data)
+		}
+	}
+	if err == nil {
+		err = w.zip.Close()
+	}
+	if err != nil {
+		w.out.Abort()
+		return fmt.Errorf("failed to save ODS file: %w", err)
+	}
+	return w.out.Close()
+}
+
+func (w *odsWriter) Abort() {
+	w.out.Abort()
+}
+
+// writeODSCell writes a value as a typed table cell
+func writeODSCell(w io.Writer, col Column, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		io.WriteString(w, "<table:table-cell/>")
+		return
+	case int64, uint64:
+		fmt.Fprintf(w, `<table:table-cell office:value-type="float" office:value="%d"/>`, v)
+		return
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			fmt.Fprintf(w, `<table:table-cell office:value-type="float" office:value="%s"/>`, strconv.FormatFloat(v, 'g', -1, 64))
+			return
+		}
+	case bool:
+		fmt.Fprintf(w, `<table:table-cell office:value-type="boolean" office:boolean-value="%t"/>`, v)
+		return
+	case time.Time:
+		style := "datetime"
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			style = "date"
+		}
+		fmt.Fprintf(w, `<table:table-cell table:style-name="%s" office:value-type="date" office:date-value="%s"/>`,
+			style, v.Format("2006-01-02T15:04:05.999999999"))
+		return
+	case string:
+		if col.IsNumeric() {
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				fmt.Fprintf(w, `<table:table-cell office:value-type="float" office:value="%s"/>`, escapeXMLAttr(v))
+				return
+			}
+		}
+	}
+
+	io.WriteString(w, `<table:table-cell office:value-type="string">`)
+	writeODSText(w, formatValue(v))
+	io.WriteString(w, "</table:table-cell>")
+}
+
+// writeODSText writes text as paragraphs, one per line
+func writeODSText(w io.Writer, s string) {
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		io.WriteString(w, "<text:p>")
+		xml.EscapeText(w, []byte(line))
+		io.WriteString(w, "</text:p>")
+	}
+}
+
+// escapeXMLAttr escapes a value for an XML attribute
+func escapeXMLAttr(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Result describes a result set passed to output writers
+type Result struct {
+	Columns    []Column
+	QueryIndex int
+	Info       QueryInfo
+	RowCount   int // rows written so far
+}
+
+// ResultWriter writes the results of a run to one output target.
+// The target is opened when the writer is created and finalized by Close.
+// Files appear under their final names only when they are finalized.
+type ResultWriter interface {
+	// BeginResult starts a new result set
+	BeginResult(res *Result) error
+	// WriteRow writes a row of the current result set
+	WriteRow(row []interface{}) error
+	// EndResult completes the current result set
+	EndResult(res *Result) error
+	// Close finalizes the output
+	Close() error
+	// Abort discards the output after a failure
+	Abort()
+}
+
+// outputTarget is an output file or stdout. It is opened when the first
+// result is routed to it and stays open until the end of the run, or until
+// its file name template expands to another name.
+type outputTarget struct {
+	config OutputConfig
+	writer ResultWriter
+	done   bool // finalized before the end of the run
+}
+
+// outputSet routes results to the output targets of a run. A result goes to
+// the -o outputs unless its query names its own outputs with directives.
+type outputSet struct {
+	base     OutputConfig // options of outputs named by directives
+	run      runInfo      // values of file name placeholders and info sheets
+	defaults []OutputConfig
+	targets  []*outputTarget          // all targets in the order they were added
+	current  map[string]*outputTarget // latest target of each file name template
+	active   []*outputTarget          // targets of the current result
+}
+
+// newOutputSet prepares the -o outputs of a run
+func newOutputSet(params *AppParams, run runInfo) *outputSet {
+	return &outputSet{
+		base:     baseOutputConfig(params),
+		run:      run,
+		defaults: params.Outputs,
+		current:  make(map[string]*outputTarget),
+	}
+}
+
+// target returns the output target of a result, adding it if needed. A file
+// name template is expanded for every result; the file of the previous
+// expansion is finalized when the name changes.
+func (o *outputSet) target(config OutputConfig, res *Result) (*outputTarget, error) {
+	template := ""
+	if isFilenameTemplate(config.Filename) {
+		template = config.Filename
+		filename, err := expandFilename(template, o.run, res.QueryIndex, res.Info)
+		if err != nil {
+			return nil, err
+		}
+		config.Filename = filename
+		config.Templated = true
+	}
+	config.Run = &o.run
+
+	var target *outputTarget
+	for _, t := range o.targets {
+		if t.config.Filename != config.Filename {
+			continue
+		}
+		if t.done {
+			return nil, fmt.Errorf("output %s has already been written", outputName(t.config.Filename))
+		}
+		if config.Format != "" && outputFormat(&t.config) != config.Format {
+			return nil, fmt.Errorf("output %s is already written as %s", outputName(t.config.Filename), outputFormat(&t.config))
+		}
+		target = t
+		break
+	}
+	if target == nil {
+		target = &outputTarget{config: config}
+		o.targets = append(o.targets, target)
+	}
+
+	if template != "" {
+		if prev := o.current[template]; prev != nil && prev != target {
+			if err := prev.finish(); err != nil {
+				return nil, fmt.Errorf("failed to finalize output: %w", err)
+			}
+		}
+		o.current[template] = target
+	}
+
+	return target, nil
+}
+
+// open creates the writer of the target
+func (t *outputTarget) open() error {
+	if t.config.Filename != "" {
+		if err := os.MkdirAll(filepath.Dir(t.config.Filename), 0755); err != nil {
+			return err
+		}
+	}
+	if t.config.Templated {
+		fmt.Fprintf(os.Stderr, "Writing %s\n", t.config.Filename)
+	}
+
+	if isSplittable(&t.config) {
+		t.writer = newSplitWriter(t.config)
+		return nil
+	}
+
+	writer, err := newResultWriter(&t.config)
+	if err != nil {
+		return err
+	}
+	t.writer = writer
+	return nil
+}
+
+// finish finalizes the target before the end of the run
+func (t *outputTarget) finish() error {
+	t.done = true
+	if t.writer == nil {
+		return nil
+	}
+	err := t.writer.Close()
+	t.writer = nil
+	return err
+}
+
+// route selects and opens the writers of a result
+func (o *outputSet) route(res *Result) error {
+	o.active = nil
+	if res.Info.SkipOutput {
+		return nil
+	}
+
+	configs := o.defaults
+	if len(res.Info.Outputs) > 0 {
+		configs = nil
+		for _, filename := range res.Info.Outputs {
+			config := o.base
+			config.Filename = filename
+			config.Format = res.Info.Format
+			configs = append(configs, config)
+		}
+	}
+
+	for _, config := range configs {
+		t, err := o.target(config, res)
+		if err != nil {
+			return err
+		}
+		if t.writer == nil {
+			if err := t.open(); err != nil {
+				return fmt.Errorf("failed to open output %s: %w", outputName(t.config.Filename), err)
+			}
+		}
+		o.active = append(o.active, t)
+	}
+	return nil
+}
+
+func (o *outputSet) BeginResult(res *Result) error {
+	if err := o.route(res); err != nil {
+		return err
+	}
+	for _, t := range o.active {
+		if err := t.writer.BeginResult(res); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	return nil
+}
+
+func (o *outputSet) WriteRow(row []interface{}) error {
+	for _, t := range o.active {
+		if err := t.writer.WriteRow(row); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	return nil
+}
+
+func (o *outputSet) EndResult(res *Result) error {
+	for _, t := range o.active {
+		if err := t.writer.EndResult(res); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	o.active = nil
+	return nil
+}
+
+// Close finalizes all opened outputs and returns the first error
+func (o *outputSet) Close() error {
+	var errs []error
+	for _, t := range o.targets {
+		if t.writer == nil {
+			continue
+		}
+		if err := t.writer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		t.writer = nil
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to finalize output: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Abort discards the outputs of a failed run, leaving the previous versions of
+// the files in place. Files from name templates whose queries completed are kept.
+func (o *outputSet) Abort() {
+	failed := make(map[*outputTarget]bool)
+	for _, t := range o.active {
+		failed[t] = true
+	}
+	for _, t := range o.targets {
+		if t.writer == nil {
+			continue
+		}
+		if t.config.Templated && !failed[t] {
+			if err := t.writer.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to finalize output %s: %v\n", outputName(t.config.Filename), err)
+			}
+		} else {
+			t.writer.Abort()
+		}
+		t.writer = nil
+	}
+	o.active = nil
+}
+
+// outputName returns a printable name of an output target
+func outputName(filename string) string {
+	if filename == "" {
+		return "stdout"
+	}
+	return filename
+}
+
+// outputFile is a buffered output target, stdout if no filename is given.
+// Data is compressed if the file name ends with .gz, .zst or .xz.
+// A file is written to a temporary file in the same directory, which
+// replaces the target file on Close and is removed on Abort.
+type outputFile struct {
+	*bufio.Writer
+	file       *os.File
+	filename   string
+	appended   bool // the file continues previous content
+	sections   int  // results written by text formats
+	compressor io.WriteCloser
+	written    *countingWriter
+	pending    *pendingFiles
+}
+
+func createOutputFile(config *OutputConfig) (*outputFile, error) {
+	out := &outputFile{file: os.Stdout, filename: config.Filename, pending: config.pending}
+	if config.Filename != "" {
+		file, err := createTempFile(config.Filename)
+		if err != nil {
+			return nil, err
+		}
+		out.file = file
+
+		// Start with a copy of the existing file. Compressed data gets a new
+		// gzip member, zstd frame or xz stream, which readers concatenate.
+		if config.Append {
+			n, err := copyExistingFile(file, config.Filename)
+			if err != nil {
+				out.Abort()
+				return nil, err
+			}
+			out.appended = n > 0
+		}
+	}
+
+	var w io.Writer = out.file
+	if method := outputCompression(config); method != CompressNone {
+		compressor, err := newCompressor(out.file, method, config.CompressLevel)
+		if err != nil {
+			out.Abort()
+			return nil, err
+		}
+		out.compressor = compressor
+		w = compressor
+	}
+
+	out.written = &countingWriter{w: w}
+	out.Writer = bufio.NewWriter(out.written)
+	return out, nil
+}
+
+// createTempFile creates a temporary file next to filename with the permissions of the existing file
+func createTempFile(filename string) (*os.File, error) {
+	file, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+// copyExistingFile copies the content of filename to w, a missing file is empty
+func copyExistingFile(w io.Writer, filename string) (int64, error) {
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(w, file)
+}
+
+// readFirstLine returns the first line of a possibly compressed file, empty if the file is missing
+func readFirstLine(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	input, err := decompressInput(file, false)
+	if err != nil {
+		return "", err
+	}
+	defer input.Close()
+
+	line, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// separateSection writes the blank line that separates the results of text
+// formats. Every result but the first gets one, and so does the first result
+// added to the content of an appended file.
+func (o *outputFile) separateSection(w io.Writer) {
+	if o.sections > 0 || o.appended {
+		fmt.Fprintln(w)
+	}
+	o.sections++
+}
+
+// Size returns the number of bytes written so far before compression, including buffered data
+func (o *outputFile) Size() int64 {
+	return o.written.n + int64(o.Buffered())
+}
+
+// Close flushes buffered data and moves the file into place, or leaves that to
+// the pending files it is added to. Stdout is not closed.
+func (o *outputFile) Close() error {
+	err := o.Flush()
+	if o.compressor != nil {
+		if cerr := o.compressor.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if o.file == os.Stdout {
+		return err
+	}
+
+	if err == nil {
+		err = o.file.Sync()
+	}
+	if cerr := o.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && o.pending != nil {
+		o.pending.add(o.file.Name(), o.filename)
+		return nil
+	}
+	if err == nil {
+		err = os.Rename(o.file.Name(), o.filename)
+	}
+	if err != nil {
+		os.Remove(o.file.Name())
+	}
+	return err
+}
+
+// Abort removes the temporary file, the target file keeps its previous content.
+// Data already written to stdout is flushed.
+func (o *outputFile) Abort() {
+	if o.file == os.Stdout {
+		o.Flush()
+		return
+	}
+	o.file.Close()
+	os.Remove(o.file.Name())
+}
+
+// pendingFiles are finished temporary files that replace their targets
+// together, so the parts of a split output appear all at once or not at all
+type pendingFiles struct {
+	temps   []string
+	targets []string
+}
+
+func (p *pendingFiles) add(temp, target string) {
+	p.temps = append(p.temps, temp)
+	p.targets = append(p.targets, target)
+}
+
+// commit moves the files into place
+func (p *pendingFiles) commit() error {
+	for i, temp := range p.temps {
+		if err := os.Rename(temp, p.targets[i]); err != nil {
+			p.temps = p.temps[i:]
+			p.targets = p.targets[i:]
+			p.discard()
+			return err
+		}
+	}
+	p.temps, p.targets = nil, nil
+	return nil
+}
+
+// discard removes the files, the targets keep their previous content
+func (p *pendingFiles) discard() {
+	for _, temp := range p.temps {
+		os.Remove(temp)
+	}
+	p.temps, p.targets = nil, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// spoolFile is a temporary file holding output that is written out later
+type spoolFile struct {
+	*bufio.Writer
+	file    *os.File
+	written *countingWriter
+}
+
+func newSpoolFile() (*spoolFile, error) {
+	file, err := os.CreateTemp("", "gocl-spool-*")
+	if err != nil {
+		return nil, err
+	}
+	written := &countingWriter{w: file}
+	return &spoolFile{Writer: bufio.NewWriter(written), file: file, written: written}, nil
+}
+
+// Size returns the number of bytes spooled, including buffered data
+func (s *spoolFile) Size() int64 {
+	return s.written.n + int64(s.Buffered())
+}
+
+// Reset discards the spooled data
+func (s *spoolFile) Reset() error {
+	s.Writer.Reset(s.written)
+	s.written.n = 0
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(0, io.SeekStart)
+	return err
+}
+
+// CopyTo writes the spooled data to w and discards it
+func (s *spoolFile) CopyTo(w io.Writer) error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, s.file); err != nil {
+		return err
+	}
+	return s.Reset()
+}
+
+// Close removes the temporary file
+func (s *spoolFile) Close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// defaultRowGroupSize is the number of rows of a Parquet row group
+const defaultRowGroupSize = 100000
+
+// parquetCodecs maps the -parquet-compression values to Parquet codecs
+var parquetCodecs = map[string]compress.Compression{
+	"snappy": compress.Codecs.Snappy,
+	"zstd":   compress.Codecs.Zstd,
+	"gzip":   compress.Codecs.Gzip,
+	"none":   compress.Codecs.Uncompressed,
+}
+
+// parquetWriter writes a result to an Apache Parquet file. Rows are collected
+// into a row group, which is written out when it is full.
+type parquetWriter struct {
+	out      *outputFile
+	props    *parquet.WriterProperties
+	rowGroup int
+	file     *pqarrow.FileWriter
+	batches  *recordBatcher
+	results  int
+}
+
+func newParquetWriter(config *OutputConfig) (*parquetWriter, error) {
+	compression := config.ParquetCompression
+	if compression == "" {
+		compression = "snappy"
+	}
+	codec, ok := parquetCodecs[compression]
+	if !ok {
+		return nil, fmt.Errorf("unknown parquet compression %q (expected snappy, zstd, gzip or none)", compression)
+	}
+	rowGroup := config.RowGroupSize
+	if rowGroup <= 0 {
+		rowGroup = defaultRowGroupSize
+	}
+
+	out, err := createOutputFile(config)
+	if err != nil {
+		return nil, err
+	}
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(codec),
+		parquet.WithMaxRowGroupLength(int64(rowGroup)),
+		parquet.WithCreatedBy("gocl "+Version),
+	)
+	return &parquetWriter{out: out, props: props, rowGroup: rowGroup}, nil
+}
+
+func (w *parquetWriter) BeginResult(res *Result) error {
+	// The file has a single schema
+	if w.results > 0 {
+		return fmt.Errorf("a parquet file holds a single result, write query %d to its own file", res.QueryIndex)
+	}
+	w.results++
+	return w.open(arrowSchema(res.Columns), res.Columns)
+}
+
+// open starts the file with the schema of the result
+func (w *parquetWriter) open(schema *arrow.Schema, columns []Column) error {
+	// The parquet writer closes its sink, the output file is closed by Close
+	file, err := pqarrow.NewFileWriter(schema, struct{ io.Writer }{w.out}, w.props,
+		pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.batches = newRecordBatcher(schema, columns, w.rowGroup, file.Write)
+	return nil
+}
+
+func (w *parquetWriter) WriteRow(row []interface{}) error {
+	return w.batches.append(row)
+}
+
+func (w *parquetWriter) EndResult(res *Result) error {
+	return w.batches.flush()
+}
+
+func (w *parquetWriter) Size() int64 {
+	return w.out.Size()
+}
+
+func (w *parquetWriter) Close() error {
+	// A file without results has no columns
+	if w.file == nil {
+		if err := w.open(arrow.NewSchema(nil, nil), nil); err != nil {
+			w.out.Abort()
+			return fmt.Errorf("failed to save parquet file: %w", err)
+		}
+	}
+	w.batches.release()
+	if err := w.file.Close(); err != nil {
+		w.out.Abort()
+		return fmt.Errorf("failed to save parquet file: %w", err)
+	}
+	return w.out.Close()
+}
+
+func (w *parquetWriter) Abort() {
+	if w.batches != nil {
+		w.batches.release()
+	}
+	w.out.Abort()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Exit codes of the ping command
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitNetwork  = 2
+	exitListener = 3
+	exitAuth     = 4
+	exitTimeout  = 5
+)
+
+var authOraCodes = map[int]bool{
+	1004:  true, // default username feature not supported
+	1005:  true, // null password given
+	1017:  true, // invalid username/password
+	1045:  true, // user lacks CREATE SESSION privilege
+	28000: true, // the account is locked
+	28001: true, // the password has expired
+}
+
+var listenerOraCodes = map[int]bool{
+	12505: true, // TNS:listener does not currently know of SID
+	12506: true, // TNS:listener rejected connection based on service ACL filtering
+	12514: true, // TNS:listener does not currently know of service
+	12516: true, // TNS:listener could not find available handler
+	12518: true, // TNS:listener could not hand off client connection
+	12519: true, // TNS:no appropriate service handler found
+	12520: true, // TNS:listener could not find available handler for requested type of server
+	12521: true, // TNS:listener does not currently know of instance
+	12526: true, // TNS:listener: all appropriate instances are in restricted mode
+	12527: true, // TNS:listener: all instances are in restricted mode or blocking new connections
+	12528: true, // TNS:listener: all appropriate instances are blocking new connections
+	12541: true, // TNS:no listener
+	12564: true, // TNS:connection refused
+}
+
+var timeoutOraCodes = map[int]bool{
+	12170: true, // TNS:Connect timeout occurred
+	12535: true, // TNS:operation timed out
+	12609: true, // TNS:Receive timeout occurred
+}
+
+// pingExitCode maps a connection error to the exit code of the ping command
+func pingExitCode(err error) int {
+	code := oraErrorCode(err)
+
+	switch {
+	case timeoutOraCodes[code] || isTimeoutError(err):
+		return exitTimeout
+	case authOraCodes[code]:
+		return exitAuth
+	case listenerOraCodes[code] || errors.Is(err, syscall.ECONNREFUSED):
+		// Connection refused means nothing listens on the port
+		return exitListener
+	case code != 0 && !transientOraCodes[code]:
+		return exitError
+	}
+
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	if errors.As(err, &dnsErr) || errors.As(err, &opErr) || isTransientError(err) {
+		return exitNetwork
+	}
+
+	return exitError
+}
+
+// runPing connects with the normal connection options, prints instance diagnostics
+// and returns the process exit code
+func runPing(params *AppParams) int {
+	fail := func(code int, err error) int {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return code
+	}
+
+	connStr, err := buildConnectionString(params)
+	if err != nil {
+		return fail(exitError, fmt.Errorf("connection error: %w", err))
+	}
+
+	db, err := sql.Open("oracle", connStr)
+	if err != nil {
+		return fail(exitError, fmt.Errorf("failed to open database: %w", err))
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if params.ConnParams.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(params.ConnParams.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	start := time.Now()
+	session, err := openSession(ctx, db, params)
+	if err != nil {
+		return fail(pingExitCode(err), fmt.Errorf("failed to connect to database: %w", err))
+	}
+	defer session.Close()
+	connectTime := time.Since(start)
+
+	conn := session.Conn()
+
+	start = time.Now()
+	var one int
+	if err := conn.QueryRowContext(ctx, "SELECT 1 FROM dual").Scan(&one); err != nil {
+		return fail(pingExitCode(err), fmt.Errorf("round trip failed: %w", err))
+	}
+	roundTrip := time.Since(start)
+
+	// Diagnostics are best effort: views like V$DATABASE need extra privileges
+	value := func(query string) string {
+		var result sql.NullString
+		if err := conn.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			if params.Debug {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", query, err)
+			}
+			return "n/a"
+		}
+		return result.String
+	}
+
+	info := []struct {
+		name  string
+		value string
+	}{
+		{"Connect time", connectTime.Round(time.Millisecond).String()},
+		{"Round trip", roundTrip.Round(time.Microsecond).String()},
+		{"Version", value("SELECT banner FROM v$version WHERE ROWNUM = 1")},
+		{"Instance", value("SELECT SYS_CONTEXT('USERENV', 'INSTANCE_NAME') FROM dual")},
+		{"Host", value("SELECT SYS_CONTEXT('USERENV', 'SERVER_HOST') FROM dual")},
+		{"Service", value("SELECT SYS_CONTEXT('USERENV', 'SERVICE_NAME') FROM dual")},
+		{"Database role", value("SELECT SYS_CONTEXT('USERENV', 'DATABASE_ROLE') FROM dual")},
+		{"Open mode", value("SELECT open_mode FROM v$database")},
+		{"Session user", value("SELECT SYS_CONTEXT('USERENV', 'SESSION_USER') FROM dual")},
+	}
+
+	for _, item := range info {
+		fmt.Printf("%-15s %s\n", item.name+":", item.value)
+	}
+
+	return exitOK
+}
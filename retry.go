@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sijms/go-ora/v2/network"
+)
+
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// transientOraCodes lists Oracle errors caused by an unavailable listener,
+// instance or network rather than by the request itself
+var transientOraCodes = map[int]bool{
+	1033:  true, // ORACLE initialization or shutdown in progress
+	1034:  true, // ORACLE not available
+	1089:  true, // immediate shutdown or close in progress
+	1090:  true, // shutdown in progress
+	1092:  true, // ORACLE instance terminated
+	3113:  true, // end-of-file on communication channel
+	3114:  true, // not connected to ORACLE
+	3135:  true, // connection lost contact
+	12170: true, // TNS:Connect timeout occurred
+	12514: true, // TNS:listener does not currently know of service
+	12516: true, // TNS:listener could not find available handler
+	12518: true, // TNS:listener could not hand off client connection
+	12519: true, // TNS:no appropriate service handler found
+	12520: true, // TNS:listener could not find available handler for requested type of server
+	12521: true, // TNS:listener does not currently know of instance
+	12526: true, // TNS:listener: all appropriate instances are in restricted mode
+	12527: true, // TNS:listener: all instances are in restricted mode or blocking new connections
+	12528: true, // TNS:listener: all appropriate instances are blocking new connections
+	12537: true, // TNS:connection closed
+	12541: true, // TNS:no listener
+	12543: true, // TNS:destination host unreachable
+	12547: true, // TNS:lost contact
+	12560: true, // TNS:protocol adapter error
+	12564: true, // TNS:connection refused
+	12571: true, // TNS:packet writer failure
+	25408: true, // can not safely replay call
+}
+
+var oraCodeRegex = regexp.MustCompile(`ORA-(\d{5})`)
+
+// oraErrorCode returns the Oracle error number of err or 0 if it is not an Oracle error
+func oraErrorCode(err error) int {
+	var oraErr *network.OracleError
+	if errors.As(err, &oraErr) {
+		return oraErr.ErrCode
+	}
+
+	// Some driver errors only carry the code in the message
+	if matches := oraCodeRegex.FindStringSubmatch(err.Error()); len(matches) > 1 {
+		code, _ := strconv.Atoi(matches[1])
+		return code
+	}
+
+	return 0
+}
+
+// isTimeoutError reports whether err is a network or context timeout
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return strings.Contains(err.Error(), "i/o timeout")
+}
+
+// isTransientError reports whether an operation that failed with err is worth retrying
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Oracle errors are classified by code: bad credentials, missing privileges
+	// and SQL errors will not go away on retry
+	if code := oraErrorCode(err); code != 0 {
+		return transientOraCodes[code]
+	}
+
+	if isTimeoutError(err) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	// The driver does not always wrap network errors, fall back to the message
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"connection refused", "connection reset", "broken pipe", "unexpected eof"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryDelay returns the exponential backoff delay before the given retry attempt (starting at 1)
+// with random jitter of up to a half of the delay
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// connectWithRetry connects and initializes the session, retrying transient
+// failures up to params.ConnectRetries times
+func connectWithRetry(ctx context.Context, db *sql.DB, params *AppParams) (*sql.Conn, error) {
+	for attempt := 0; ; attempt++ {
+		conn, err := connect(ctx, db, params)
+		if err == nil {
+			if params.Debug && attempt > 0 {
+				fmt.Fprintf(os.Stderr, "Connected after %d retries\n", attempt)
+			}
+			return conn, nil
+		}
+
+		if attempt >= params.ConnectRetries || !isTransientError(err) {
+			return nil, err
+		}
+
+		delay := retryDelay(attempt + 1)
+		if params.Debug {
+			fmt.Fprintf(os.Stderr, "Connect attempt %d of %d failed: %v (retrying in %s)\n",
+				attempt+1, params.ConnectRetries+1, err, delay.Round(time.Millisecond))
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isIdempotentQuery reports whether a query only reads data and can be safely re-executed
+func isIdempotentQuery(query string) bool {
+	s := strings.TrimSpace(query)
+	for {
+		switch {
+		case strings.HasPrefix(s, "--"):
+			if idx := strings.Index(s, "\n"); idx != -1 {
+				s = strings.TrimSpace(s[idx+1:])
+			} else {
+				return false
+			}
+		case strings.HasPrefix(s, "/*"):
+			if idx := strings.Index(s, "*/"); idx != -1 {
+				s = strings.TrimSpace(s[idx+2:])
+			} else {
+				return false
+			}
+		case strings.HasPrefix(s, "("):
+			s = strings.TrimSpace(s[1:])
+		default:
+			fields := strings.Fields(s)
+			if len(fields) == 0 {
+				return false
+			}
+			keyword := strings.ToUpper(fields[0])
+			return keyword == "SELECT" || keyword == "WITH"
+		}
+	}
+}
+
+// rowSpool keeps the rows of a query that may be retried in a temporary file,
+// so they are passed on only after the fetch completed. Every row is stored
+// with its length in front and read back in order.
+type rowSpool struct {
+	file   *os.File
+	writer *bufio.Writer
+	buf    []byte
+}
+
+func newRowSpool() (*rowSpool, error) {
+	file, err := os.CreateTemp("", "gocl-retry-*")
+	if err != nil {
+		return nil, err
+	}
+	return &rowSpool{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// append stores a row
+func (s *rowSpool) append(row []interface{}) error {
+	var err error
+	s.buf, err = appendSpillRow(s.buf[:0], row)
+	if err != nil {
+		return fmt.Errorf("failed to spool row: %w", err)
+	}
+	var length [binary.MaxVarintLen64]byte
+	s.writer.Write(length[:binary.PutUvarint(length[:], uint64(len(s.buf)))])
+	if _, err := s.writer.Write(s.buf); err != nil {
+		return fmt.Errorf("failed to spool row: %w", err)
+	}
+	return nil
+}
+
+// reset drops the stored rows before the query is run again
+func (s *rowSpool) reset() error {
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(0, io.SeekStart)
+	s.writer.Reset(s.file)
+	return err
+}
+
+// replay passes the stored rows to fn in the order they were stored
+func (s *rowSpool) replay(columns int, fn func([]interface{}) error) error {
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to spool row: %w", err)
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(s.file)
+	for {
+		length, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read spooled row: %w", err)
+		}
+		s.buf = slices.Grow(s.buf[:0], int(length))[:length]
+		if _, err := io.ReadFull(reader, s.buf); err != nil {
+			return fmt.Errorf("failed to read spooled row: %w", err)
+		}
+		row, err := decodeSpillRow(s.buf, columns)
+		if err != nil {
+			return fmt.Errorf("failed to read spooled row: %w", err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// Close removes the temporary file
+func (s *rowSpool) Close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
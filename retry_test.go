@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRowSpoolReplaysRowsInOrder(t *testing.T) {
+	spool, err := newRowSpool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spool.Close()
+
+	// A failed attempt leaves rows behind that the next one drops
+	if err := spool.append([]interface{}{"lost", int64(0), nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := spool.reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	moment := time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)
+	rows := [][]interface{}{
+		{"O'Brien", int64(1), moment},
+		{nil, int64(-2), moment},
+		{"", int64(3), nil},
+	}
+	for _, row := range rows {
+		if err := spool.append(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got [][]interface{}
+	err = spool.replay(3, func(row []interface{}) error {
+		got = append(got, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("replayed %v, want %v", got, rows)
+	}
+}
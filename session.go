@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SessionParams holds settings applied to every new database session
+type SessionParams struct {
+	NLS         []string // NLS settings in KEY=VALUE form
+	LoginScript string
+	ClientID    string
+	Container   string
+}
+
+// Session is the database connection used by a run. Unlike a pooled *sql.DB
+// it keeps session state and can be re-established after the connection is lost.
+type Session struct {
+	db     *sql.DB
+	conn   *sql.Conn
+	params *AppParams
+}
+
+// openSession connects to the database, retrying transient failures
+func openSession(ctx context.Context, db *sql.DB, params *AppParams) (*Session, error) {
+	conn, err := connectWithRetry(ctx, db, params)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{db: db, conn: conn, params: params}, nil
+}
+
+// Conn returns the current connection
+func (s *Session) Conn() *sql.Conn {
+	return s.conn
+}
+
+// Reconnect drops the current connection and opens a new initialized one
+func (s *Session) Reconnect(ctx context.Context) error {
+	// Mark the connection as bad so the pool does not hand it out again
+	s.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	s.conn.Close()
+
+	conn, err := connectWithRetry(ctx, s.db, s.params)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// Close returns the connection to the pool
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
+
+// identifierRegex matches a plain (unquoted) Oracle identifier
+var identifierRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_$#]*$`)
+
+// connect takes a dedicated connection from the pool and initializes the session on it.
+// Session settings are per connection, so all queries of a run must use the returned connection.
+func connect(ctx context.Context, db *sql.DB, params *AppParams) (*sql.Conn, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := initSession(ctx, conn, params); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("session initialization failed: %w", err)
+	}
+
+	return conn, nil
+}
+
+// initSession switches the container, applies NLS settings, tags the session
+// and runs the login script
+func initSession(ctx context.Context, conn *sql.Conn, params *AppParams) error {
+	session := params.Session
+
+	if session.Container != "" {
+		if !identifierRegex.MatchString(session.Container) {
+			return fmt.Errorf("invalid container name: %s", session.Container)
+		}
+		if err := execSessionStatement(ctx, conn, params.Debug, "ALTER SESSION SET CONTAINER = "+session.Container); err != nil {
+			return err
+		}
+	}
+
+	for _, setting := range session.NLS {
+		stmt, err := buildNLSStatement(setting)
+		if err != nil {
+			return err
+		}
+		if err := execSessionStatement(ctx, conn, params.Debug, stmt); err != nil {
+			return err
+		}
+	}
+
+	// Tag the session so it can be identified in V$SESSION
+	action := ""
+	if params.InputFile != "" {
+		action = filepath.Base(params.InputFile)
+	}
+	if err := execSessionStatement(ctx, conn, params.Debug,
+		"BEGIN DBMS_APPLICATION_INFO.SET_MODULE(:1, :2); END;", "gocl", action); err != nil {
+		return err
+	}
+
+	if session.ClientID != "" {
+		if err := execSessionStatement(ctx, conn, params.Debug,
+			"BEGIN DBMS_SESSION.SET_IDENTIFIER(:1); END;", session.ClientID); err != nil {
+			return err
+		}
+	}
+
+	if session.LoginScript != "" {
+		if err := runLoginScript(ctx, conn, params); err != nil {
+			return fmt.Errorf("login script %s: %w", session.LoginScript, err)
+		}
+	}
+
+	return nil
+}
+
+// buildNLSStatement converts a KEY=VALUE setting into an ALTER SESSION statement.
+// The key is used as given, session parameters like TIME_ZONE have no NLS_ prefix.
+func buildNLSStatement(setting string) (string, error) {
+	parts := strings.SplitN(setting, "=", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid NLS setting: %s (expected KEY=VALUE)", setting)
+	}
+
+	key := strings.ToUpper(strings.TrimSpace(parts[0]))
+	if !identifierRegex.MatchString(key) {
+		return "", fmt.Errorf("invalid NLS parameter name: %s", parts[0])
+	}
+
+	// Quote value as a string literal
+	value := strings.ReplaceAll(parts[1], "'", "''")
+
+	return fmt.Sprintf("ALTER SESSION SET %s = '%s'", key, value), nil
+}
+
+func execSessionStatement(ctx context.Context, conn *sql.Conn, debug bool, stmt string, args ...interface{}) error {
+	if debug {
+		fmt.Fprintf(os.Stderr, "Session: %s\n", stmt)
+	}
+	if _, err := conn.ExecContext(ctx, stmt, args...); err != nil {
+		return fmt.Errorf("%s: %w", stmt, err)
+	}
+	return nil
+}
+
+// runLoginScript executes statements from the login script, separated by '/' like the main input
+func runLoginScript(ctx context.Context, conn *sql.Conn, params *AppParams) error {
+	file, err := os.Open(params.Session.LoginScript)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := detectAndConvertEncoding(file, params.Debug)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(reader)
+	var buffer strings.Builder
+
+	flush := func() error {
+		stmt := substituteParams(cleanQuery(buffer.String()), params.Params)
+		buffer.Reset()
+		if stmt == "" {
+			return nil
+		}
+		return execSessionStatement(ctx, conn, params.Debug, stmt)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isCommandSeparator(line) {
+			if err := flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		if buffer.Len() > 0 {
+			buffer.WriteString("\n")
+		}
+		buffer.WriteString(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestBuildNLSStatement(t *testing.T) {
+	tests := []struct {
+		setting, want string
+	}{
+		{"NLS_DATE_FORMAT=DD.MM.YYYY", "ALTER SESSION SET NLS_DATE_FORMAT = 'DD.MM.YYYY'"},
+		{"nls_language=GERMAN", "ALTER SESSION SET NLS_LANGUAGE = 'GERMAN'"},
+		{"TIME_ZONE=Europe/Berlin", "ALTER SESSION SET TIME_ZONE = 'Europe/Berlin'"},
+		{"NLS_DATE_FORMAT=DD' 'MON", "ALTER SESSION SET NLS_DATE_FORMAT = 'DD'' ''MON'"},
+	}
+	for _, tt := range tests {
+		got, err := buildNLSStatement(tt.setting)
+		if err != nil {
+			t.Errorf("buildNLSStatement(%q): %v", tt.setting, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("buildNLSStatement(%q) = %s, want %s", tt.setting, got, tt.want)
+		}
+	}
+
+	for _, setting := range []string{"NLS_DATE_FORMAT", "NLS DATE=x", "1=2"} {
+		if _, err := buildNLSStatement(setting); err == nil {
+			t.Errorf("buildNLSStatement(%q) accepted an invalid setting", setting)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// chartTypes maps the types of the chart directive to Excel chart types
+var chartTypes = map[string]excelize.ChartType{
+	"line":    excelize.Line,
+	"area":    excelize.Area,
+	"bar":     excelize.Bar,
+	"column":  excelize.Col,
+	"col":     excelize.Col,
+	"pie":     excelize.Pie,
+	"scatter": excelize.Scatter,
+}
+
+// chartSpec is a chart of a result sheet, e.g. "line x=DAY y=AMOUNT,COUNT"
+type chartSpec struct {
+	Type excelize.ChartType
+	X    string   // column of the categories, none if empty
+	Y    []string // columns of the series
+}
+
+// parseChart parses the value of a chart directive
+func parseChart(value string) (chartSpec, error) {
+	fields := strings.Fields(value)
+	chartType, ok := chartTypes[strings.ToLower(fields[0])]
+	if !ok {
+		return chartSpec{}, fmt.Errorf("unknown chart type %q (expected line, area, bar, column, pie or scatter)", fields[0])
+	}
+
+	spec := chartSpec{Type: chartType}
+	for _, field := range fields[1:] {
+		key, columns, found := strings.Cut(field, "=")
+		if !found || columns == "" {
+			return spec, fmt.Errorf("invalid chart option %q", field)
+		}
+		switch strings.ToLower(key) {
+		case "x":
+			spec.X = columns
+		case "y":
+			spec.Y = append(spec.Y, strings.Split(columns, ",")...)
+		default:
+			return spec, fmt.Errorf("unknown chart option %q", key)
+		}
+	}
+	if len(spec.Y) == 0 {
+		return spec, fmt.Errorf("chart %q needs y=columns", value)
+	}
+	return spec, nil
+}
+
+// highlightColors are the fill and font colors of highlight directives,
+// the presets of Excel's conditional formatting
+var highlightColors = map[string][2]string{
+	"red":    {"FFC7CE", "9C0006"},
+	"green":  {"C6EFCE", "006100"},
+	"yellow": {"FFEB9C", "9C5700"},
+	"orange": {"FCD5B4", "974706"},
+	"blue":   {"BDD7EE", "1F4E78"},
+	"gray":   {"D9D9D9", "000000"},
+	"grey":   {"D9D9D9", "000000"},
+}
+
+// hexColorRegex matches a color like #FF8080
+var hexColorRegex = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// conditionRegex matches a condition like STATUS='FAILED' or AMOUNT >= 1000
+var conditionRegex = regexp.MustCompile(`^("[^"]+"|[\w$#]+)\s*(<>|!=|<=|>=|=|<|>)\s*(.+)$`)
+
+// highlightSpec colors the rows of a result that meet a condition, e.g. "STATUS='FAILED':red"
+type highlightSpec struct {
+	Column   string
+	Operator string // Excel operator
+	Value    string // Excel formula literal
+	Fill     string
+	Font     string
+}
+
+// parseHighlight parses the value of a highlight directive
+func parseHighlight(value string) (highlightSpec, error) {
+	idx := strings.LastIndex(value, ":")
+	if idx == -1 {
+		return highlightSpec{}, fmt.Errorf("highlight %q needs a color, e.g. STATUS='FAILED':red", value)
+	}
+	condition, color := strings.TrimSpace(value[:idx]), strings.TrimSpace(value[idx+1:])
+
+	var spec highlightSpec
+	if colors, ok := highlightColors[strings.ToLower(color)]; ok {
+		spec.Fill, spec.Font = colors[0], colors[1]
+	} else if hexColorRegex.MatchString(color) {
+		spec.Fill = strings.ToUpper(color[1:])
+	} else {
+		return spec, fmt.Errorf("unknown color %q (expected red, green, yellow, orange, blue, gray or #RRGGBB)", color)
+	}
+
+	matches := conditionRegex.FindStringSubmatch(condition)
+	if matches == nil {
+		return spec, fmt.Errorf("invalid highlight condition %q, expected COLUMN=value", condition)
+	}
+	spec.Column = strings.Trim(matches[1], `"`)
+	spec.Operator = matches[2]
+	if spec.Operator == "!=" {
+		spec.Operator = "<>"
+	}
+
+	// A quoted SQL string or a number
+	literal := strings.TrimSpace(matches[3])
+	if len(literal) >= 2 && strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") {
+		text := strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+		spec.Value = `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+	} else if _, err := strconv.ParseFloat(literal, 64); err == nil {
+		spec.Value = literal
+	} else {
+		return spec, fmt.Errorf("invalid highlight value %s, expected 'text' or a number", literal)
+	}
+	return spec, nil
+}
+
+// columnIndex returns the position of a column, ignoring case
+func columnIndex(columns []Column, name string) int {
+	for i, col := range columns {
+		if strings.EqualFold(col.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkSheetFormats reports chart and highlight columns missing from a result
+func checkSheetFormats(info QueryInfo, columns []Column) error {
+	var names []string
+	for _, chart := range info.Charts {
+		if chart.X != "" {
+			names = append(names, chart.X)
+		}
+		names = append(names, chart.Y...)
+	}
+	for _, highlight := range info.Highlights {
+		names = append(names, highlight.Column)
+	}
+	for _, name := range names {
+		if columnIndex(columns, name) == -1 {
+			return fmt.Errorf("column %s of a chart or highlight directive not found in the result", name)
+		}
+	}
+	return nil
+}
+
+// addSheetFormats adds the charts and conditional formats of a result to a
+// sheet holding its rows firstRow to lastRow from column firstCol on. For a
+// sheet of a stream writer it has to be called before the writer is flushed.
+func addSheetFormats(f *excelize.File, sheet string, info QueryInfo, columns []Column, firstCol, headerRow, firstRow, lastRow int) error {
+	if lastRow < firstRow {
+		return nil
+	}
+
+	// columnRange returns the absolute reference of a column's data
+	quoted := "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+	columnRange := func(name string) string {
+		col, _ := excelize.ColumnNumberToName(firstCol + columnIndex(columns, name))
+		return fmt.Sprintf("%s!$%s$%d:$%s$%d", quoted, col, firstRow, col, lastRow)
+	}
+
+	// Charts are placed to the right of the data, one below the other
+	anchorCol, _ := excelize.ColumnNumberToName(firstCol + len(columns) + 1)
+	topRow := firstRow
+	if headerRow > 0 {
+		topRow = headerRow
+	}
+	for i, spec := range info.Charts {
+		chart := &excelize.Chart{
+			Type:      spec.Type,
+			Dimension: excelize.ChartDimension{Width: 640, Height: 320},
+			Legend:    excelize.ChartLegend{Position: "bottom"},
+		}
+		if title := resultHeading(info); title != "" {
+			chart.Title = []excelize.RichTextRun{{Text: title}}
+		}
+		for _, y := range spec.Y {
+			series := excelize.ChartSeries{Name: columns[columnIndex(columns, y)].Name, Values: columnRange(y)}
+			if headerRow > 0 {
+				col, _ := excelize.ColumnNumberToName(firstCol + columnIndex(columns, y))
+				series.Name = fmt.Sprintf("%s!$%s$%d", quoted, col, headerRow)
+			}
+			if spec.X != "" {
+				series.Categories = columnRange(spec.X)
+			}
+			chart.Series = append(chart.Series, series)
+		}
+		anchor := fmt.Sprintf("%s%d", anchorCol, topRow+1+i*17)
+		if err := f.AddChart(sheet, anchor, chart); err != nil {
+			return fmt.Errorf("failed to add chart: %w", err)
+		}
+	}
+
+	// Highlighted rows
+	startCol, _ := excelize.ColumnNumberToName(firstCol)
+	lastCol, _ := excelize.ColumnNumberToName(firstCol + len(columns) - 1)
+	rows := fmt.Sprintf("%s%d:%s%d", startCol, firstRow, lastCol, lastRow)
+	var formats []excelize.ConditionalFormatOptions
+	for _, spec := range info.Highlights {
+		style := &excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{spec.Fill}}}
+		if spec.Font != "" {
+			style.Font = &excelize.Font{Color: spec.Font}
+		}
+		format, err := f.NewConditionalStyle(style)
+		if err != nil {
+			return err
+		}
+		col, _ := excelize.ColumnNumberToName(firstCol + columnIndex(columns, spec.Column))
+		formula := fmt.Sprintf("$%s%d%s%s", col, firstRow, spec.Operator, spec.Value)
+		formats = append(formats, excelize.ConditionalFormatOptions{Type: "formula", Criteria: formula, Format: &format})
+	}
+	if len(formats) > 0 {
+		if err := f.SetConditionalFormat(sheet, rows, formats); err != nil {
+			return fmt.Errorf("failed to add highlight: %w", err)
+		}
+	}
+	return nil
+}
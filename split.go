@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sizer is implemented by writers that know the size of their output so far
+type sizer interface {
+	Size() int64
+}
+
+// isSplittable reports whether an output is written in parts of limited size.
+// Excel outputs continue on new sheets instead, databases are not split and
+// stdout is never split.
+func isSplittable(config *OutputConfig) bool {
+	if config.Filename == "" || (config.MaxRows <= 0 && config.MaxBytes <= 0) {
+		return false
+	}
+	switch outputFormat(config) {
+	case XLS, XLSX, ODS, SQLITE:
+		return false
+	}
+	return true
+}
+
+// partFilename returns the name of part n of an output: report.csv, report_2.csv, report_3.csv ...
+func partFilename(filename string, n int) string {
+	if n == 1 {
+		return filename
+	}
+	// report.csv.gz continues in report_2.csv.gz
+	name, _ := splitCompressionExt(filename)
+	compressionExt := strings.TrimPrefix(filename, name)
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s_%d%s%s", strings.TrimSuffix(name, ext), n, ext, compressionExt)
+}
+
+// splitWriter writes an output as numbered files of at most MaxRows rows or
+// about MaxBytes bytes. A file is finished once it reaches a limit, and the
+// result continues with its header in the next file. The finished files stay
+// temporary until Close, so a failed run leaves no partial set of parts behind.
+type splitWriter struct {
+	config OutputConfig
+	writer ResultWriter
+	part   int
+	parts  pendingFiles
+	rows   int     // rows in the current file
+	res    *Result // current result as written to the current file
+}
+
+func newSplitWriter(config OutputConfig) *splitWriter {
+	return &splitWriter{config: config}
+}
+
+// open starts the next file
+func (w *splitWriter) open() error {
+	w.part++
+	config := w.config
+	config.Filename = partFilename(w.config.Filename, w.part)
+	config.pending = &w.parts
+	if w.part > 1 {
+		fmt.Fprintf(os.Stderr, "Writing %s\n", config.Filename)
+	}
+
+	writer, err := newResultWriter(&config)
+	if err != nil {
+		return fmt.Errorf("failed to open output %s: %w", config.Filename, err)
+	}
+	if _, ok := writer.(sizer); !ok && w.config.MaxBytes > 0 {
+		writer.Abort()
+		return fmt.Errorf("-max-bytes-per-file is not supported by the %s format", outputFormat(&config))
+	}
+	w.writer = writer
+	w.rows = 0
+	return nil
+}
+
+// full reports whether the current file has reached a limit
+func (w *splitWriter) full() bool {
+	if w.rows == 0 {
+		return false
+	}
+	if w.config.MaxRows > 0 && w.rows >= w.config.MaxRows {
+		return true
+	}
+	if s, ok := w.writer.(sizer); ok && w.config.MaxBytes > 0 && s.Size() >= w.config.MaxBytes {
+		return true
+	}
+	return false
+}
+
+func (w *splitWriter) BeginResult(res *Result) error {
+	if w.writer == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	part := *res
+	w.res = &part
+	return w.writer.BeginResult(w.res)
+}
+
+func (w *splitWriter) WriteRow(row []interface{}) error {
+	if w.full() {
+		// Finish this file and continue the result in the next one
+		w.res.Info.Elapsed = time.Since(w.res.Info.Started)
+		if err := w.writer.EndResult(w.res); err != nil {
+			return err
+		}
+		if err := w.writer.Close(); err != nil {
+			return err
+		}
+		if err := w.open(); err != nil {
+			return err
+		}
+		part := *w.res
+		part.RowCount = 0
+		w.res = &part
+		if err := w.writer.BeginResult(w.res); err != nil {
+			return err
+		}
+	}
+
+	w.rows++
+	w.res.RowCount++
+	return w.writer.WriteRow(row)
+}
+
+func (w *splitWriter) EndResult(res *Result) error {
+	w.res.Info.Elapsed = res.Info.Elapsed
+	return w.writer.EndResult(w.res)
+}
+
+func (w *splitWriter) Close() error {
+	if w.writer == nil {
+		return nil
+	}
+	if err := w.writer.Close(); err != nil {
+		w.parts.discard()
+		return err
+	}
+	return w.parts.commit()
+}
+
+func (w *splitWriter) Abort() {
+	if w.writer != nil {
+		w.writer.Abort()
+	}
+	w.parts.discard()
+}
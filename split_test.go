@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSplit writes rows of one column to a split csv output and finishes it with Close or Abort
+func writeSplit(t *testing.T, filename string, rows int, abort bool) {
+	t.Helper()
+	w := newSplitWriter(OutputConfig{Filename: filename, Format: CSV, MaxRows: 2})
+	res := &Result{Columns: []Column{{Name: "ID", Type: "NUMBER"}}, QueryIndex: 1}
+	if err := w.BeginResult(res); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < rows; i++ {
+		if err := w.WriteRow([]interface{}{int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if abort {
+		w.Abort()
+		return
+	}
+	if err := w.EndResult(res); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSplitWriterRenamesPartsOnClose(t *testing.T) {
+	dir := t.TempDir()
+	writeSplit(t, filepath.Join(dir, "report.csv"), 5, false)
+
+	for _, name := range []string{"report.csv", "report_2.csv", "report_3.csv"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("part %s missing: %v", name, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("got %d files, want 3 parts without temporary files", len(entries))
+	}
+}
+
+func TestSplitWriterAbortRemovesAllParts(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "report.csv")
+	if err := os.WriteFile(filename, []byte("previous\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeSplit(t, filename, 5, true)
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want only the previous report.csv", len(entries))
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "previous\n" {
+		t.Errorf("report.csv = %q, want the previous content", data)
+	}
+}
+
+func TestSplitWriterMaxBytes(t *testing.T) {
+	for _, format := range []OutputFormat{CSV, TABLE, HTML, MD, JIRA, CONFLUENCE, SQL, XML} {
+		dir := t.TempDir()
+		w := newSplitWriter(OutputConfig{Filename: filepath.Join(dir, "report"), Format: format, MaxBytes: 200})
+		res := &Result{Columns: []Column{{Name: "NAME", Type: "NCHAR"}}, QueryIndex: 1, Info: QueryInfo{TableName: "T"}}
+		if err := w.BeginResult(res); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			if err := w.WriteRow([]interface{}{"a value of some length"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.EndResult(res); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) < 2 {
+			t.Errorf("%s: got %d files, want the output split into parts", format, len(entries))
+		}
+	}
+}
+
+func TestPartFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		n        int
+		want     string
+	}{
+		{"report.csv", 1, "report.csv"},
+		{"report.csv", 2, "report_2.csv"},
+		{"out/dump.tsv.gz", 3, "out/dump_3.tsv.gz"},
+	}
+	for _, tt := range tests {
+		if got := partFilename(tt.filename, tt.n); got != tt.want {
+			t.Errorf("partFilename(%q, %d) = %q, want %q", tt.filename, tt.n, got, tt.want)
+		}
+	}
+}
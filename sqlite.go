@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// defaultSQLiteBatch is the number of rows inserted per SQLite transaction
+const defaultSQLiteBatch = 10000
+
+// sqliteType maps a result column to a SQLite column type. Only binary floating
+// point numbers are REAL, the NUMERIC affinity keeps other numbers as integers
+// where they fit. Dates are stored as ISO 8601 text, which the SQLite date and
+// time functions understand.
+func sqliteType(col Column) string {
+	switch {
+	case col.IsNumeric():
+		switch col.Type {
+		case "BFLOAT", "IBFLOAT", "BINARY_FLOAT", "BDOUBLE", "IBDOUBLE", "BINARY_DOUBLE":
+			return "REAL"
+		case "SB1", "UINT":
+			return "INTEGER"
+		}
+		if col.Precision > 0 && col.Scale == 0 {
+			return "INTEGER"
+		}
+		return "NUMERIC"
+	case col.IsTime():
+		return "TEXT"
+	case col.IsBinary():
+		return "BLOB"
+	}
+	return "TEXT"
+}
+
+// sqliteName quotes an identifier
+func sqliteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqliteValue converts a database value to a value SQLite stores
+func sqliteValue(col Column, v interface{}) interface{} {
+	t, ok := v.(time.Time)
+	if !ok {
+		return v
+	}
+	if strings.Contains(col.Type, "TZ") {
+		return t.Format("2006-01-02 15:04:05.999999999-07:00")
+	}
+	return t.Format("2006-01-02 15:04:05.999999999")
+}
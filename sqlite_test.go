@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestSQLiteType(t *testing.T) {
+	tests := []struct {
+		col  Column
+		want string
+	}{
+		{Column{Type: "NUMBER", Precision: 10}, "INTEGER"},
+		{Column{Type: "NUMBER", Precision: 10, Scale: 2}, "NUMERIC"},
+		{Column{Type: "NUMBER"}, "NUMERIC"},
+		{Column{Type: "NUMBER", Scale: 255}, "NUMERIC"},
+		{Column{Type: "NUMBER", Precision: 126, Scale: 255}, "NUMERIC"},
+		{Column{Type: "SB1"}, "INTEGER"},
+		{Column{Type: "IBDOUBLE"}, "REAL"},
+		{Column{Type: "BFLOAT"}, "REAL"},
+		{Column{Type: "DATE"}, "TEXT"},
+		{Column{Type: "RAW"}, "BLOB"},
+		{Column{Type: "NCHAR"}, "TEXT"},
+	}
+	for _, tt := range tests {
+		if got := sqliteType(tt.col); got != tt.want {
+			t.Errorf("sqliteType(%+v) = %s, want %s", tt.col, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build solaris
+
+package main
+
+import "fmt"
+
+// newSQLiteWriter reports that the pure Go SQLite driver does not support Solaris
+func newSQLiteWriter(config *OutputConfig) (ResultWriter, error) {
+	return nil, fmt.Errorf("sqlite output is not supported on this platform")
+}
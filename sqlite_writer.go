@@ -0,0 +1,249 @@
+//go:build !solaris
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteWriter writes every result to its own table of a SQLite database.
+// The database is built in a temporary file, which replaces the target file
+// on Close like other outputs.
+type sqliteWriter struct {
+	filename string
+	temp     string
+	db       *sql.DB
+	tx       *sql.Tx
+	insert   string    // INSERT statement of the current result
+	stmt     *sql.Stmt // insert prepared in the current transaction
+	batch    int
+	rows     int // rows of the current transaction
+	columns  []Column
+	tables   map[string]bool // tables written by this run
+	existing bool            // the database continues an existing file
+}
+
+func newSQLiteWriter(config *OutputConfig) (*sqliteWriter, error) {
+	if config.Filename == "" {
+		return nil, fmt.Errorf("the sqlite format needs an output file")
+	}
+	if outputCompression(config) != CompressNone {
+		return nil, fmt.Errorf("sqlite databases cannot be compressed")
+	}
+
+	// Start with a copy of the existing database when appending
+	file, err := createTempFile(config.Filename)
+	if err != nil {
+		return nil, err
+	}
+	w := &sqliteWriter{filename: config.Filename, temp: file.Name(), batch: config.SQLiteBatch, tables: make(map[string]bool)}
+	if w.batch <= 0 {
+		w.batch = defaultSQLiteBatch
+	}
+	if config.Append {
+		n, err := copyExistingFile(file, config.Filename)
+		if err != nil {
+			file.Close()
+			os.Remove(w.temp)
+			return nil, err
+		}
+		w.existing = n > 0
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(w.temp)
+		return nil, err
+	}
+
+	w.db, err = sql.Open("sqlite", w.temp)
+	if err == nil {
+		// A single connection keeps the settings. The file is discarded if the
+		// run fails, so there is no need for a journal.
+		w.db.SetMaxOpenConns(1)
+		_, err = w.db.Exec("PRAGMA journal_mode = OFF; PRAGMA synchronous = OFF")
+	}
+	if err != nil {
+		w.Abort()
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	return w, nil
+}
+
+func (w *sqliteWriter) BeginResult(res *Result) error {
+	table := res.Info.TargetTable
+	if table == "" {
+		table = partName(res.Info)
+	}
+	if table == "" {
+		table = fmt.Sprintf("Results%d", res.QueryIndex)
+	}
+	// Another result of this run with the same name gets its own table
+	name := table
+	for n := 2; w.tables[strings.ToLower(name)]; n++ {
+		name = fmt.Sprintf("%s_%d", table, n)
+	}
+	w.tables[strings.ToLower(name)] = true
+	w.columns = res.Columns
+
+	// Columns need unique names
+	used := make(map[string]bool)
+	names := make([]string, len(res.Columns))
+	defs := make([]string, len(res.Columns))
+	for i, col := range res.Columns {
+		names[i] = col.Name
+		for n := 2; used[strings.ToLower(names[i])]; n++ {
+			names[i] = fmt.Sprintf("%s_%d", col.Name, n)
+		}
+		used[strings.ToLower(names[i])] = true
+		defs[i] = sqliteName(names[i]) + " " + sqliteType(col)
+	}
+
+	if err := w.createTable(name, names, defs); err != nil {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	w.insert = fmt.Sprintf("INSERT INTO %s VALUES (%s)", sqliteName(name), placeholders)
+	return w.begin()
+}
+
+// createTable creates the table of a result. The rows of an appended database
+// are added to an existing table of the name if its columns match.
+func (w *sqliteWriter) createTable(name string, names, defs []string) error {
+	if w.existing {
+		existing, err := w.tableColumns(name)
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			if !strings.EqualFold(strings.Join(existing, ","), strings.Join(names, ",")) {
+				return fmt.Errorf("columns of table %s in %s do not match the result: table has %q, result has %q",
+					name, w.filename, strings.Join(existing, ","), strings.Join(names, ","))
+			}
+			return nil
+		}
+	}
+
+	ddl := fmt.Sprintf("CREATE TABLE %s (%s)", sqliteName(name), strings.Join(defs, ", "))
+	if _, err := w.db.Exec(ddl); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", name, err)
+	}
+	return nil
+}
+
+// tableColumns returns the column names of a table, none if it does not exist
+func (w *sqliteWriter) tableColumns(name string) ([]string, error) {
+	rows, err := w.db.Query("SELECT name FROM pragma_table_info(?) ORDER BY cid", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// begin starts a transaction for the next batch of rows
+func (w *sqliteWriter) begin() error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(w.insert)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	w.tx = tx
+	w.stmt = stmt
+	w.rows = 0
+	return nil
+}
+
+// commit ends the transaction of the current batch
+func (w *sqliteWriter) commit() error {
+	if w.tx == nil {
+		return nil
+	}
+	// Committing closes the prepared statement
+	err := w.tx.Commit()
+	w.tx = nil
+	w.stmt = nil
+	return err
+}
+
+func (w *sqliteWriter) WriteRow(row []interface{}) error {
+	values := make([]interface{}, len(row))
+	for i, v := range row {
+		values[i] = sqliteValue(w.columns[i], v)
+	}
+	if _, err := w.stmt.Exec(values...); err != nil {
+		return err
+	}
+
+	w.rows++
+	if w.rows >= w.batch {
+		if err := w.commit(); err != nil {
+			return err
+		}
+		return w.begin()
+	}
+	return nil
+}
+
+func (w *sqliteWriter) EndResult(res *Result) error {
+	return w.commit()
+}
+
+func (w *sqliteWriter) Close() error {
+	err := w.commit()
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+	// The database is written without syncing, so it goes to disk before it replaces the target
+	if err == nil {
+		err = syncFile(w.temp)
+	}
+	if err == nil {
+		err = os.Rename(w.temp, w.filename)
+	}
+	if err != nil {
+		os.Remove(w.temp)
+		return fmt.Errorf("failed to save SQLite database: %w", err)
+	}
+	return nil
+}
+
+func (w *sqliteWriter) Abort() {
+	if w.tx != nil {
+		w.tx.Rollback()
+	}
+	if w.db != nil {
+		w.db.Close()
+	}
+	os.Remove(w.temp)
+}
+
+// syncFile writes the data of a closed file to disk
+func syncFile(filename string) error {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	err = file.Sync()
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// sqlReservedWords are the Oracle reserved words that cannot be used as unquoted names
+var sqlReservedWords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`ACCESS ADD ALL ALTER AND ANY AS ASC AUDIT BETWEEN BY CHAR
+		CHECK CLUSTER COLUMN COMMENT COMPRESS CONNECT CREATE CURRENT DATE DECIMAL DEFAULT DELETE
+		DESC DISTINCT DROP ELSE EXCLUSIVE EXISTS FILE FLOAT FOR FROM GRANT GROUP HAVING IDENTIFIED
+		IMMEDIATE IN INCREMENT INDEX INITIAL INSERT INTEGER INTERSECT INTO IS LEVEL LIKE LOCK LONG
+		MAXEXTENTS MINUS MLSLABEL MODE MODIFY NOAUDIT NOCOMPRESS NOT NOWAIT NULL NUMBER OF OFFLINE
+		ON ONLINE OPTION OR ORDER PCTFREE PRIOR PUBLIC RAW RENAME RESOURCE REVOKE ROW ROWID ROWNUM
+		ROWS SELECT SESSION SET SHARE SIZE SMALLINT START SUCCESSFUL SYNONYM SYSDATE TABLE THEN TO
+		TRIGGER UID UNION UNIQUE UPDATE USER VALIDATE VALUES VARCHAR VARCHAR2 VIEW WHENEVER WHERE WITH`) {
+		sqlReservedWords[word] = true
+	}
+}
+
+// sqlNameRegex matches names that don't need quotes
+var sqlNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_$#]*$`)
+
+// sqlNumberRegex matches decimal numbers that can be written as numeric literals
+var sqlNumberRegex = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// sqlColumnName quotes a column name unless it is an upper case name,
+// which is what Oracle stores for unquoted names
+func sqlColumnName(name string) string {
+	if sqlNameRegex.MatchString(name) && name == strings.ToUpper(name) && !sqlReservedWords[name] {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqlTableName quotes the parts of a table name given as SCHEMA.TABLE that are not plain names
+func sqlTableName(name string) string {
+	if strings.Contains(name, `"`) {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if !sqlNameRegex.MatchString(part) || sqlReservedWords[strings.ToUpper(part)] {
+			parts[i] = `"` + part + `"`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// Longest text written as a single literal. Longer text is joined from
+// TO_CLOB pieces, it would exceed the 4000 byte limit of literals.
+const sqlMaxLiteral = 1000
+
+// sqlString quotes text as a literal. Line breaks are written as CHR(10)
+// and CHR(13), so every statement stays on one line.
+func sqlString(s string) string {
+	if utf8.RuneCountInString(s) > sqlMaxLiteral {
+		var pieces []string
+		runes := []rune(s)
+		for len(runes) > 0 {
+			n := min(len(runes), sqlMaxLiteral)
+			pieces = append(pieces, "TO_CLOB("+sqlString(string(runes[:n]))+")")
+			runes = runes[n:]
+		}
+		return strings.Join(pieces, " || ")
+	}
+
+	quoted := "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	quoted = strings.ReplaceAll(quoted, "\r", "' || CHR(13) || '")
+	quoted = strings.ReplaceAll(quoted, "\n", "' || CHR(10) || '")
+	return strings.TrimSuffix(strings.TrimPrefix(quoted, "'' || "), " || ''")
+}
+
+// sqlLiteral returns a value as an Oracle SQL literal
+func sqlLiteral(col Column, v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case int64, uint64:
+		return fmt.Sprint(v)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "BINARY_DOUBLE_NAN"
+		case math.IsInf(v, 1):
+			return "BINARY_DOUBLE_INFINITY"
+		case math.IsInf(v, -1):
+			return "-BINARY_DOUBLE_INFINITY"
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		switch col.Type {
+		case "DATE", "OCIDATE":
+			return fmt.Sprintf("TO_DATE('%s', 'YYYY-MM-DD HH24:MI:SS')", v.Format("2006-01-02 15:04:05"))
+		case "TIMESTAMPTZ", "TIMESTAMPTZ_DTY", "TIMETZ", "TIMESTAMPLTZ_DTY", "TIMESTAMPELTZ":
+			return fmt.Sprintf("TO_TIMESTAMP_TZ('%s', 'YYYY-MM-DD HH24:MI:SS.FF9 TZH:TZM')", v.Format("2006-01-02 15:04:05.000000000 -07:00"))
+		}
+		return fmt.Sprintf("TO_TIMESTAMP('%s', 'YYYY-MM-DD HH24:MI:SS.FF9')", v.Format("2006-01-02 15:04:05.000000000"))
+	case []byte:
+		return "HEXTORAW('" + strings.ToUpper(hex.EncodeToString(v)) + "')"
+	case string:
+		// Numbers with more digits than a float64 holds come as text
+		if col.IsNumeric() && sqlNumberRegex.MatchString(v) {
+			return v
+		}
+	}
+	return sqlString(formatValue(v))
+}
+
+// sqlColumnType returns the column definition of CREATE TABLE for a result column
+func sqlColumnType(col Column) string {
+	size := func(name string, length int64, fallback int64) string {
+		if length <= 0 {
+			length = fallback
+		}
+		return fmt.Sprintf("%s(%d CHAR)", name, length)
+	}
+
+	switch col.Type {
+	case "NUMBER", "VARNUM":
+		switch {
+		case col.HasFloatingScale() && col.Precision > 0:
+			return "FLOAT"
+		case col.HasFloatingScale():
+			return "NUMBER"
+		case col.Precision > 0 && col.Scale > 0:
+			return fmt.Sprintf("NUMBER(%d,%d)", col.Precision, col.Scale)
+		case col.Precision > 0 && col.Scale == 0:
+			return fmt.Sprintf("NUMBER(%d)", col.Precision)
+		}
+		return "NUMBER"
+	case "FLOAT":
+		return "FLOAT"
+	case "BFLOAT", "IBFLOAT", "BINARY_FLOAT":
+		return "BINARY_FLOAT"
+	case "BDOUBLE", "IBDOUBLE", "BINARY_DOUBLE":
+		return "BINARY_DOUBLE"
+	case "NCHAR", "VARCHAR", "OCISTRING":
+		return size("VARCHAR2", col.Length, 4000)
+	case "CHAR", "CHARZ":
+		return size("CHAR", col.Length, 1)
+	case "LONG", "LONGVARCHAR", "OCICLOBLOCATOR", "OCIXMLTYPE", "XMLTYPE":
+		return "CLOB"
+	case "RAW", "VARRAW":
+		return "RAW(2000)"
+	case "LONGRAW", "LONGVARRAW", "OCIBLOBLOCATOR":
+		return "BLOB"
+	case "DATE", "OCIDATE":
+		return "DATE"
+	case "TIMESTAMP", "TIMESTAMPDTY":
+		return "TIMESTAMP"
+	case "TIMESTAMPTZ", "TIMESTAMPTZ_DTY", "TIMETZ":
+		return "TIMESTAMP WITH TIME ZONE"
+	case "TIMESTAMPLTZ_DTY", "TIMESTAMPELTZ":
+		return "TIMESTAMP WITH LOCAL TIME ZONE"
+	case "INTERVALYM", "INTERVALYM_DTY":
+		return "INTERVAL YEAR TO MONTH"
+	case "INTERVALDS", "INTERVALDS_DTY":
+		return "INTERVAL DAY TO SECOND"
+	}
+	if col.IsNumeric() {
+		return "NUMBER"
+	}
+	return "VARCHAR2(4000 CHAR)"
+}
+
+// sqlWriter writes results as SQL statements that load the rows into a table:
+// INSERT statements, INSERT ALL batches, or MERGE statements on key columns
+type sqlWriter struct {
+	out         *outputFile
+	withHeader  bool
+	batch       int    // rows per statement
+	commit      int    // rows between COMMIT statements, 0 = none
+	create      bool   // CREATE TABLE before the rows of a table
+	mergeKey    string // key columns of MERGE statements, INSERT if empty
+	created     map[string]bool
+	uncommitted int
+
+	// Current result
+	table   string
+	columns []Column
+	names   []string // column names as written
+	key     []int    // positions of the merge key columns
+	pending [][]string
+}
+
+func newSQLWriter(config *OutputConfig, withHeader bool) (*sqlWriter, error) {
+	out, err := createOutputFile(config)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlWriter{
+		out:        out,
+		withHeader: withHeader,
+		batch:      max(config.SQLBatch, 1),
+		commit:     config.SQLCommit,
+		create:     config.SQLCreate,
+		mergeKey:   config.SQLMergeKey,
+		created:    make(map[string]bool),
+	}, nil
+}
+
+func (w *sqlWriter) BeginResult(res *Result) error {
+	table := res.Info.TargetTable
+	if table == "" {
+		table = res.Info.TableName
+	}
+	if table == "" {
+		return fmt.Errorf("sql output of query %d needs a table name: add a -- target-table= or -- tab= directive", res.QueryIndex)
+	}
+	w.table = sqlTableName(table)
+	w.columns = res.Columns
+	w.pending = nil
+
+	w.names = make([]string, len(res.Columns))
+	for i, col := range res.Columns {
+		w.names[i] = sqlColumnName(col.Name)
+	}
+
+	w.key = nil
+	mergeKey := w.mergeKey
+	if res.Info.MergeKey != "" {
+		mergeKey = res.Info.MergeKey
+	}
+	if mergeKey != "" {
+		for _, name := range strings.Split(mergeKey, ",") {
+			idx := columnIndex(res.Columns, strings.TrimSpace(name))
+			if idx == -1 {
+				return fmt.Errorf("merge key column %s not found in the result", strings.TrimSpace(name))
+			}
+			w.key = append(w.key, idx)
+		}
+	}
+
+	w.out.separateSection(w.out)
+	if w.withHeader && !res.Info.NoHeader {
+		fmt.Fprintf(w.out, "-- %s\n", strings.ReplaceAll(resultTitle(res.QueryIndex, res.Info), "\n", " "))
+	}
+
+	if w.create && !w.created[w.table] {
+		w.created[w.table] = true
+		fmt.Fprintf(w.out, "CREATE TABLE %s (\n", w.table)
+		for i, col := range res.Columns {
+			separator := ","
+			if i == len(res.Columns)-1 {
+				separator = ""
+			}
+			fmt.Fprintf(w.out, "    %s %s%s\n", w.names[i], sqlColumnType(col), separator)
+		}
+		fmt.Fprint(w.out, ");\n\n")
+	}
+	return nil
+}
+
+func (w *sqlWriter) WriteRow(row []interface{}) error {
+	values := make([]string, len(row))
+	for i, v := range row {
+		values[i] = sqlLiteral(w.columns[i], v)
+	}
+	w.pending = append(w.pending, values)
+	if len(w.pending) >= w.batch {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush writes the statement of the pending rows
+func (w *sqlWriter) flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	columns := strings.Join(w.names, ", ")
+
+	switch {
+	case w.key != nil:
+		w.writeMerge()
+	case len(w.pending) == 1:
+		fmt.Fprintf(w.out, "INSERT INTO %s (%s) VALUES (%s);\n", w.table, columns, strings.Join(w.pending[0], ", "))
+	default:
+		fmt.Fprintln(w.out, "INSERT ALL")
+		for _, values := range w.pending {
+			fmt.Fprintf(w.out, "    INTO %s (%s) VALUES (%s)\n", w.table, columns, strings.Join(values, ", "))
+		}
+		fmt.Fprintln(w.out, "SELECT * FROM dual;")
+	}
+
+	w.uncommitted += len(w.pending)
+	w.pending = nil
+	if w.commit > 0 && w.uncommitted >= w.commit {
+		w.uncommitted = 0
+		fmt.Fprintln(w.out, "COMMIT;")
+	}
+	return nil
+}
+
+// writeMerge writes a MERGE statement that updates the rows with existing keys and inserts the others
+func (w *sqlWriter) writeMerge() {
+	fmt.Fprintf(w.out, "MERGE INTO %s t\nUSING (\n", w.table)
+	for i, values := range w.pending {
+		selected := make([]string, len(values))
+		for j, value := range values {
+			selected[j] = value + " AS " + w.names[j]
+		}
+		prefix := "    "
+		if i > 0 {
+			prefix = "    UNION ALL "
+		}
+		fmt.Fprintf(w.out, "%sSELECT %s FROM dual\n", prefix, strings.Join(selected, ", "))
+	}
+
+	isKey := make(map[int]bool)
+	var on []string
+	for _, idx := range w.key {
+		isKey[idx] = true
+		on = append(on, fmt.Sprintf("t.%s = s.%s", w.names[idx], w.names[idx]))
+	}
+	fmt.Fprintf(w.out, ") s\nON (%s)\n", strings.Join(on, " AND "))
+
+	var set, sourceValues []string
+	for i, name := range w.names {
+		if !isKey[i] {
+			set = append(set, fmt.Sprintf("t.%s = s.%s", name, name))
+		}
+		sourceValues = append(sourceValues, "s."+name)
+	}
+	if len(set) > 0 {
+		fmt.Fprintf(w.out, "WHEN MATCHED THEN UPDATE SET %s\n", strings.Join(set, ", "))
+	}
+	fmt.Fprintf(w.out, "WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);\n",
+		strings.Join(w.names, ", "), strings.Join(sourceValues, ", "))
+}
+
+func (w *sqlWriter) EndResult(res *Result) error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if w.commit > 0 && w.uncommitted > 0 {
+		w.uncommitted = 0
+		fmt.Fprintln(w.out, "COMMIT;")
+	}
+	return nil
+}
+
+func (w *sqlWriter) Size() int64 {
+	return w.out.Size()
+}
+
+func (w *sqlWriter) Close() error {
+	return w.out.Close()
+}
+
+func (w *sqlWriter) Abort() {
+	w.out.Abort()
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLLiteral(t *testing.T) {
+	number := Column{Name: "N", Type: "NUMBER"}
+	text := Column{Name: "S", Type: "NCHAR"}
+	moment := time.Date(2024, 3, 1, 12, 30, 45, 0, time.FixedZone("", 2*3600))
+	tests := []struct {
+		col  Column
+		v    interface{}
+		want string
+	}{
+		{text, nil, "NULL"},
+		{number, nil, "NULL"},
+		{text, "O'Brien", "'O''Brien'"},
+		{text, "", "''"},
+		{text, "a\nb", "'a' || CHR(10) || 'b'"},
+		{number, int64(-42), "-42"},
+		{number, 1.5, "1.5"},
+		{number, math.NaN(), "BINARY_DOUBLE_NAN"},
+		{number, math.Inf(-1), "-BINARY_DOUBLE_INFINITY"},
+		{number, true, "1"},
+		{number, "123456789012345678901234567890.123", "123456789012345678901234567890.123"},
+		{number, "1; DROP TABLE T", "'1; DROP TABLE T'"},
+		{text, "12345678901234567890", "'12345678901234567890'"},
+		{Column{Type: "DATE"}, moment, "TO_DATE('2024-03-01 12:30:45', 'YYYY-MM-DD HH24:MI:SS')"},
+		{Column{Type: "TIMESTAMP"}, moment, "TO_TIMESTAMP('2024-03-01 12:30:45.000000000', 'YYYY-MM-DD HH24:MI:SS.FF9')"},
+		{Column{Type: "TIMESTAMPTZ"}, moment, "TO_TIMESTAMP_TZ('2024-03-01 12:30:45.000000000 +02:00', 'YYYY-MM-DD HH24:MI:SS.FF9 TZH:TZM')"},
+		{Column{Type: "RAW"}, []byte{0xde, 0xad, 0x01}, "HEXTORAW('DEAD01')"},
+	}
+	for _, tt := range tests {
+		if got := sqlLiteral(tt.col, tt.v); got != tt.want {
+			t.Errorf("sqlLiteral(%s, %#v) = %s, want %s", tt.col.Type, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestSQLStringLong(t *testing.T) {
+	got := sqlString(strings.Repeat("x", sqlMaxLiteral+1))
+	want := "TO_CLOB('" + strings.Repeat("x", sqlMaxLiteral) + "') || TO_CLOB('x')"
+	if got != want {
+		t.Errorf("sqlString of %d characters = %.40s..., want TO_CLOB pieces", sqlMaxLiteral+1, got)
+	}
+}
+
+func TestSQLNames(t *testing.T) {
+	columns := []struct{ in, want string }{
+		{"ID", "ID"},
+		{"id", `"id"`},
+		{"ORDER", `"ORDER"`},
+		{"FIRST NAME", `"FIRST NAME"`},
+		{`A"B`, `"A""B"`},
+	}
+	for _, tt := range columns {
+		if got := sqlColumnName(tt.in); got != tt.want {
+			t.Errorf("sqlColumnName(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	tables := []struct{ in, want string }{
+		{"HR.EMPLOYEES", "HR.EMPLOYEES"},
+		{"APP.ORDER", `APP."ORDER"`},
+		{"hr.my table", `hr."my table"`},
+		{`"Hr"."T"`, `"Hr"."T"`},
+	}
+	for _, tt := range tables {
+		if got := sqlTableName(tt.in); got != tt.want {
+			t.Errorf("sqlTableName(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSQLColumnType(t *testing.T) {
+	tests := []struct {
+		col  Column
+		want string
+	}{
+		{Column{Type: "NUMBER", Precision: 10, Scale: 2}, "NUMBER(10,2)"},
+		{Column{Type: "NUMBER", Precision: 9}, "NUMBER(9)"},
+		{Column{Type: "NUMBER"}, "NUMBER"},
+		{Column{Type: "NUMBER", Precision: 0, Scale: 255}, "NUMBER"},
+		{Column{Type: "NUMBER", Precision: 126, Scale: 255}, "FLOAT"},
+		{Column{Type: "NUMBER", Precision: 38, Scale: 129}, "FLOAT"},
+		{Column{Type: "IBDOUBLE"}, "BINARY_DOUBLE"},
+		{Column{Type: "NCHAR", Length: 30}, "VARCHAR2(30 CHAR)"},
+		{Column{Type: "NCHAR"}, "VARCHAR2(4000 CHAR)"},
+		{Column{Type: "TIMESTAMPTZ"}, "TIMESTAMP WITH TIME ZONE"},
+	}
+	for _, tt := range tests {
+		if got := sqlColumnType(tt.col); got != tt.want {
+			t.Errorf("sqlColumnType(%+v) = %s, want %s", tt.col, got, tt.want)
+		}
+	}
+}
+
+// writeSQLScript writes rows to a sql output and returns the script
+func writeSQLScript(t *testing.T, config OutputConfig, info QueryInfo, rows [][]interface{}) string {
+	t.Helper()
+	config.Filename = filepath.Join(t.TempDir(), "out.sql")
+	w, err := newSQLWriter(&config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &Result{Columns: []Column{{Name: "ID", Type: "NUMBER"}, {Name: "NAME", Type: "NCHAR"}}, QueryIndex: 1, Info: info}
+	if err := w.BeginResult(res); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.EndResult(res); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(config.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSQLWriterStatements(t *testing.T) {
+	rows := [][]interface{}{{int64(1), "O'Brien"}, {int64(2), nil}, {int64(3), "a|b"}}
+	tests := []struct {
+		name   string
+		config OutputConfig
+		info   QueryInfo
+		want   string
+	}{
+		{
+			name:   "insert",
+			config: OutputConfig{SQLBatch: 1},
+			info:   QueryInfo{TableName: "T"},
+			want: "INSERT INTO T (ID, NAME) VALUES (1, 'O''Brien');\n" +
+				"INSERT INTO T (ID, NAME) VALUES (2, NULL);\n" +
+				"INSERT INTO T (ID, NAME) VALUES (3, 'a|b');\n",
+		},
+		{
+			name:   "insert all",
+			config: OutputConfig{SQLBatch: 2, SQLCommit: 2},
+			info:   QueryInfo{TableName: "APP.ORDER"},
+			want: "INSERT ALL\n" +
+				"    INTO APP.\"ORDER\" (ID, NAME) VALUES (1, 'O''Brien')\n" +
+				"    INTO APP.\"ORDER\" (ID, NAME) VALUES (2, NULL)\n" +
+				"SELECT * FROM dual;\n" +
+				"COMMIT;\n" +
+				"INSERT INTO APP.\"ORDER\" (ID, NAME) VALUES (3, 'a|b');\n" +
+				"COMMIT;\n",
+		},
+		{
+			name:   "merge",
+			config: OutputConfig{SQLBatch: 2, SQLMergeKey: "id"},
+			info:   QueryInfo{TableName: "T"},
+			want: "MERGE INTO T t\nUSING (\n" +
+				"    SELECT 1 AS ID, 'O''Brien' AS NAME FROM dual\n" +
+				"    UNION ALL SELECT 2 AS ID, NULL AS NAME FROM dual\n" +
+				") s\nON (t.ID = s.ID)\n" +
+				"WHEN MATCHED THEN UPDATE SET t.NAME = s.NAME\n" +
+				"WHEN NOT MATCHED THEN INSERT (ID, NAME) VALUES (s.ID, s.NAME);\n" +
+				"MERGE INTO T t\nUSING (\n" +
+				"    SELECT 3 AS ID, 'a|b' AS NAME FROM dual\n" +
+				") s\nON (t.ID = s.ID)\n" +
+				"WHEN MATCHED THEN UPDATE SET t.NAME = s.NAME\n" +
+				"WHEN NOT MATCHED THEN INSERT (ID, NAME) VALUES (s.ID, s.NAME);\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := writeSQLScript(t, tt.config, tt.info, rows); got != tt.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSQLWriterMissingMergeKey(t *testing.T) {
+	config := OutputConfig{Filename: filepath.Join(t.TempDir(), "out.sql"), SQLMergeKey: "CODE"}
+	w, err := newSQLWriter(&config, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Abort()
+	res := &Result{Columns: []Column{{Name: "ID", Type: "NUMBER"}}, QueryIndex: 1, Info: QueryInfo{TableName: "T"}}
+	if err := w.BeginResult(res); err == nil {
+		t.Error("BeginResult accepted a merge key that is not a column of the result")
+	}
+}
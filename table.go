@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+	"golang.org/x/text/width"
+)
+
+// Border styles of the table format
+const (
+	BorderUnicode = "unicode"
+	BorderASCII   = "ascii"
+)
+
+// minTableColumnWidth is the narrowest a column gets when the table is shrunk to the terminal width
+const minTableColumnWidth = 3
+
+type tableBorder struct {
+	horizontal, vertical                  string
+	topLeft, topMiddle, topRight          string
+	middleLeft, middleMiddle, middleRight string
+	bottomLeft, bottomMiddle, bottomRight string
+	ellipsis                              string
+}
+
+var unicodeTableBorder = tableBorder{
+	horizontal: "─", vertical: "│",
+	topLeft: "┌", topMiddle: "┬", topRight: "┐",
+	middleLeft: "├", middleMiddle: "┼", middleRight: "┤",
+	bottomLeft: "└", bottomMiddle: "┴", bottomRight: "┘",
+	ellipsis: "…",
+}
+
+var asciiTableBorder = tableBorder{
+	horizontal: "-", vertical: "|",
+	topLeft: "+", topMiddle: "+", topRight: "+",
+	middleLeft: "+", middleMiddle: "+", middleRight: "+",
+	bottomLeft: "+", bottomMiddle: "+", bottomRight: "+",
+	ellipsis: "~",
+}
+
+// isTerminal reports whether f is connected to a terminal
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// terminalSize returns the width and height of the terminal attached to stdout or zeros if there is none
+func terminalSize() (int, int) {
+	w, h, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 0, 0
+	}
+	return w, h
+}
+
+// runeWidth returns the number of terminal cells occupied by r
+func runeWidth(r rune) int {
+	if r < 0x20 || r == 0x7f || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// displayWidth returns the number of terminal cells occupied by s
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// truncateToWidth cuts s to at most w cells, marking the cut with ellipsis
+func truncateToWidth(s string, w int, ellipsis string) string {
+	if displayWidth(s) <= w {
+		return s
+	}
+
+	limit := w - displayWidth(ellipsis)
+	var b strings.Builder
+	cur := 0
+	for _, r := range s {
+		rw := runeWidth(r)
+		if cur+rw > limit {
+			break
+		}
+		b.WriteRune(r)
+		cur += rw
+	}
+	b.WriteString(ellipsis)
+	return b.String()
+}
+
+// padToWidth aligns s in a field of w cells
+func padToWidth(s string, w int, alignRight bool) string {
+	pad := w - displayWidth(s)
+	if pad <= 0 {
+		return s
+	}
+	if alignRight {
+		return strings.Repeat(" ", pad) + s
+	}
+	return s + strings.Repeat(" ", pad)
+}
+
+// tableCell makes a value printable on a single line
+func tableCell(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ").Replace(s)
+}
+
+// tableWriter writes aligned tables. Column widths depend on all rows,
+// so the rows of a result are collected and rendered when it ends.
+type tableWriter struct {
+	out        *outputFile
+	withHeader bool
+	vertical   bool
+	border     tableBorder
+	toTerminal bool
+	columns    []Column
+	cells      [][]string
+	cellBytes  int64 // text of the collected cells
+}
+
+func newTableWriter(config *OutputConfig, withHeader bool) (*tableWriter, error) {
+	out, err := createOutputFile(config)
+	if err != nil {
+		return nil, err
+	}
+
+	border := unicodeTableBorder
+	if config.Border == BorderASCII {
+		border = asciiTableBorder
+	}
+
+	return &tableWriter{
+		out:        out,
+		withHeader: withHeader,
+		vertical:   config.Vertical,
+		border:     border,
+		// Fit the table to the terminal only when printing to it
+		toTerminal: config.Filename == "" && config.Compression == CompressNone && isTerminal(os.Stdout),
+	}, nil
+}
+
+func (w *tableWriter) BeginResult(res *Result) error {
+	w.columns = res.Columns
+	w.cells = nil
+	w.cellBytes = 0
+	return nil
+}
+
+func (w *tableWriter) WriteRow(row []interface{}) error {
+	cells := formatRow(row)
+	for _, cell := range cells {
+		w.cellBytes += int64(len(cell)) + 1
+	}
+	w.cells = append(w.cells, cells)
+	return nil
+}
+
+// Size returns the size of the written tables and the text of the rows
+// collected for the current one, which grows by its borders and padding
+func (w *tableWriter) Size() int64 {
+	return w.out.Size() + w.cellBytes
+}
+
+func (w *tableWriter) EndResult(res *Result) error {
+	maxWidth := 0
+	if w.toTerminal {
+		maxWidth, _ = terminalSize()
+	}
+
+	var buf bytes.Buffer
+
+	w.out.separateSection(&buf)
+
+	// Write table name as header if available
+	if name := partName(res.Info); name != "" {
+		fmt.Fprintln(&buf, name)
+	}
+
+	if w.vertical {
+		renderVerticalTable(&buf, w.columns, w.cells, w.border, maxWidth)
+	} else {
+		renderTable(&buf, w.columns, w.cells, w.withHeader && !res.Info.NoHeader, w.border, maxWidth)
+	}
+
+	fmt.Fprintf(&buf, "(%s)\n", formatRowCount(len(w.cells)))
+	w.cells = nil
+	w.cellBytes = 0
+
+	if w.toTerminal {
+		return pageOutput(buf.Bytes())
+	}
+
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+func (w *tableWriter) Close() error {
+	return w.out.Close()
+}
+
+func (w *tableWriter) Abort() {
+	w.out.Abort()
+}
+
+// renderTable writes rows as an aligned table with borders
+func renderTable(w io.Writer, columns []Column, data [][]string, withHeader bool, border tableBorder, maxWidth int) {
+	widths := make([]int, len(columns))
+	for i, col := range columns {
+		if withHeader {
+			widths[i] = displayWidth(col.Name)
+		}
+		for _, row := range data {
+			if cw := displayWidth(tableCell(row[i])); cw > widths[i] {
+				widths[i] = cw
+			}
+		}
+	}
+
+	// Shrink the widest columns until the table fits the terminal
+	if maxWidth > 0 {
+		total := 1 + 3*len(widths)
+		for _, cw := range widths {
+			total += cw
+		}
+		for total > maxWidth {
+			widest := 0
+			for i := range widths {
+				if widths[i] > widths[widest] {
+					widest = i
+				}
+			}
+			if widths[widest] <= minTableColumnWidth {
+				break
+			}
+			widths[widest]--
+			total--
+		}
+	}
+
+	line := func(left, middle, right string) {
+		parts := make([]string, len(widths))
+		for i, cw := range widths {
+			parts[i] = strings.Repeat(border.horizontal, cw+2)
+		}
+		fmt.Fprintln(w, left+strings.Join(parts, middle)+right)
+	}
+
+	row := func(cells []string, alignNumbers bool) {
+		var b strings.Builder
+		b.WriteString(border.vertical)
+		for i, cell := range cells {
+			text := truncateToWidth(tableCell(cell), widths[i], border.ellipsis)
+			b.WriteString(" ")
+			b.WriteString(padToWidth(text, widths[i], alignNumbers && columns[i].IsNumeric()))
+			b.WriteString(" ")
+			b.WriteString(border.vertical)
+		}
+		fmt.Fprintln(w, b.String())
+	}
+
+	line(border.topLeft, border.topMiddle, border.topRight)
+	if withHeader {
+		row(columnNames(columns), true)
+		line(border.middleLeft, border.middleMiddle, border.middleRight)
+	}
+	for _, r := range data {
+		row(r, true)
+	}
+	line(border.bottomLeft, border.bottomMiddle, border.bottomRight)
+}
+
+// renderVerticalTable writes every row as a record with one column per line
+func renderVerticalTable(w io.Writer, columns []Column, data [][]string, border tableBorder, maxWidth int) {
+	nameWidth := 0
+	for _, col := range columns {
+		if cw := displayWidth(col.Name); cw > nameWidth {
+			nameWidth = cw
+		}
+	}
+
+	valueWidth := 0
+	for _, row := range data {
+		for _, cell := range row {
+			if cw := displayWidth(tableCell(cell)); cw > valueWidth {
+				valueWidth = cw
+			}
+		}
+	}
+	if maxWidth > 0 && nameWidth+3+valueWidth > maxWidth {
+		valueWidth = max(maxWidth-nameWidth-3, minTableColumnWidth)
+	}
+
+	for i, row := range data {
+		title := fmt.Sprintf("%s[ RECORD %d ]", border.horizontal, i+1)
+		if pad := nameWidth + 3 + valueWidth - displayWidth(title); pad > 0 {
+			title += strings.Repeat(border.horizontal, pad)
+		}
+		fmt.Fprintln(w, title)
+
+		for j, cell := range row {
+			value := truncateToWidth(tableCell(cell), valueWidth, border.ellipsis)
+			fmt.Fprintf(w, "%s %s %s\n", padToWidth(columns[j].Name, nameWidth, false), border.vertical, value)
+		}
+	}
+}
+
+// pageOutput prints text to the terminal, passing it through $PAGER when it does not fit the screen
+func pageOutput(text []byte) error {
+	pager := os.Getenv("PAGER")
+	_, height := terminalSize()
+	if pager == "" || height == 0 || bytes.Count(text, []byte("\n")) < height {
+		writer := bufio.NewWriter(os.Stdout)
+		writer.Write(text)
+		return writer.Flush()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", pager)
+	} else {
+		cmd = exec.Command("sh", "-c", pager)
+	}
+	cmd.Stdin = bytes.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pager %s failed: %w", pager, err)
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// excelMaxDigits is the number of significant digits an Excel number keeps
+const excelMaxDigits = 15
+
+// excelValue converts a database value to an Excel cell value. Numbers stay
+// numbers so formulas can use them, unless they would lose digits.
+func excelValue(col Column, v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64, uint64, float64:
+		return v
+	case string:
+		if col.IsNumeric() && significantDigits(v) <= excelMaxDigits {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+	}
+	return formatValue(v)
+}
+
+// significantDigits counts the digits of a decimal number without leading zeros
+func significantDigits(s string) int {
+	digits := strings.TrimLeft(strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s), "0")
+	return len(digits)
+}
+
+// truncateCell cuts text longer than an Excel cell can hold and reports whether it did
+func truncateCell(value interface{}) (interface{}, bool) {
+	text, ok := value.(string)
+	if !ok || utf8.RuneCountInString(text) <= excelMaxCellChars {
+		return value, false
+	}
+	return string([]rune(text)[:excelMaxCellChars]), true
+}
+
+// truncateRunes cuts s to at most n characters
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// sheetNames holds the lower case names of the sheets written to a workbook
+type sheetNames map[string]bool
+
+// unique returns name, or name with a " (2)", " (3)" ... suffix if a sheet
+// of this name has been written already. Sheet names are compared ignoring case.
+func (n sheetNames) unique(name string) string {
+	unique := name
+	for i := 2; n[strings.ToLower(unique)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		unique = strings.TrimRight(truncateRunes(name, excelMaxSheetName-len(suffix)), " ") + suffix
+	}
+	n[strings.ToLower(unique)] = true
+	return unique
+}
+
+// excelTarget is the place in a workbook a result is written to
+type excelTarget struct {
+	sheet string
+	col   int
+	row   int
+}
+
+// resolveTarget parses a target directive: a cell like Data!A5 or 'My data'!$B$2,
+// or the name of a defined range, which is filled from its top left cell
+func resolveTarget(f *excelize.File, target string) (*excelTarget, error) {
+	ref := target
+	if !strings.Contains(ref, "!") {
+		ref = ""
+		for _, name := range f.GetDefinedName() {
+			if strings.EqualFold(name.Name, target) {
+				ref = strings.TrimPrefix(name.RefersTo, "=")
+				break
+			}
+		}
+		if ref == "" {
+			return nil, fmt.Errorf("target %s: no such cell or named range", target)
+		}
+	}
+
+	idx := strings.LastIndex(ref, "!")
+	if idx == -1 {
+		return nil, fmt.Errorf("target %s: %s is not a cell reference", target, ref)
+	}
+	sheet := ref[:idx]
+	if strings.HasPrefix(sheet, "'") && strings.HasSuffix(sheet, "'") && len(sheet) > 1 {
+		sheet = strings.ReplaceAll(sheet[1:len(sheet)-1], "''", "'")
+	}
+
+	// The first cell of a range
+	cell := strings.ReplaceAll(ref[idx+1:], "$", "")
+	if before, _, found := strings.Cut(cell, ":"); found {
+		cell = before
+	}
+	col, row, err := excelize.CellNameToCoordinates(cell)
+	if err != nil {
+		return nil, fmt.Errorf("target %s: %w", target, err)
+	}
+
+	return &excelTarget{sheet: sheet, col: col, row: row}, nil
+}
+
+// fillTarget writes rows at a target. The row at the target is the model for
+// the data: it takes the first row, the others are inserted below it with its
+// cell styles. Formulas, named ranges and tables that include the model row
+// grow over the inserted rows.
+func fillTarget(f *excelize.File, target *excelTarget, columns int, rows [][]interface{}) error {
+	index, err := f.GetSheetIndex(target.sheet)
+	if err != nil {
+		return err
+	}
+	if index == -1 {
+		if _, err := f.NewSheet(target.sheet); err != nil {
+			return err
+		}
+	}
+
+	if len(rows) > 1 {
+		inserted := len(rows) - 1
+		// Ranges that reach below the model row grow by the insert, the
+		// ones that end at it are extended afterwards
+		if err := f.InsertRows(target.sheet, target.row+1, inserted); err != nil {
+			return err
+		}
+		if err := extendRanges(f, target.sheet, target.row, inserted); err != nil {
+			return err
+		}
+
+		for c := 0; c < columns; c++ {
+			modelCell, err := excelize.CoordinatesToCellName(target.col+c, target.row)
+			if err != nil {
+				return err
+			}
+			style, err := f.GetCellStyle(target.sheet, modelCell)
+			if err != nil {
+				return err
+			}
+			if style == 0 {
+				continue
+			}
+			first, _ := excelize.CoordinatesToCellName(target.col+c, target.row+1)
+			last, _ := excelize.CoordinatesToCellName(target.col+c, target.row+inserted)
+			if err := f.SetCellStyle(target.sheet, first, last, style); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, values := range rows {
+		cell, err := excelize.CoordinatesToCellName(target.col, target.row+i)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(target.sheet, cell, &values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rangeRefRegex matches a range reference like B5:B9, $A$5:$B$5 or 'My data'!A1:C5
+var rangeRefRegex = regexp.MustCompile(`((?:'(?:[^']|'')+'|[\w.]+)!)?(\$?[A-Za-z]{1,3}\$?)(\d+):(\$?[A-Za-z]{1,3}\$?)(\d+)`)
+
+// extendRefs extends the range references to sheet in a formula that end at
+// row by n rows. References without a sheet name are on sheet if local is set.
+func extendRefs(formula, sheet string, local bool, row, n int) string {
+	return rangeRefRegex.ReplaceAllStringFunc(formula, func(ref string) string {
+		m := rangeRefRegex.FindStringSubmatch(ref)
+		refSheet := strings.TrimSuffix(m[1], "!")
+		if len(refSheet) > 1 && strings.HasPrefix(refSheet, "'") {
+			refSheet = strings.ReplaceAll(refSheet[1:len(refSheet)-1], "''", "'")
+		}
+		if (refSheet == "" && !local) || (refSheet != "" && !strings.EqualFold(refSheet, sheet)) {
+			return ref
+		}
+		first, _ := strconv.Atoi(m[3])
+		last, _ := strconv.Atoi(m[5])
+		if last != row || first > row {
+			return ref
+		}
+		return m[1] + m[2] + m[3] + ":" + m[4] + strconv.Itoa(last+n)
+	})
+}
+
+// extendFormulas extends the range references of the formulas of formulaSheet
+func extendFormulas(f *excelize.File, formulaSheet, sheet string, row, n int) error {
+	rows, err := f.Rows(formulaSheet)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for r := 1; rows.Next(); r++ {
+		values, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		for c := range values {
+			cell, _ := excelize.CoordinatesToCellName(c+1, r)
+			formula, err := f.GetCellFormula(formulaSheet, cell)
+			if err != nil || formula == "" {
+				continue
+			}
+			if extended := extendRefs(formula, sheet, formulaSheet == sheet, row, n); extended != formula {
+				if err := f.SetCellFormula(formulaSheet, cell, extended); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return rows.Error()
+}
+
+// extendRanges extends the formulas, named ranges and tables whose range ends
+// at row of sheet over the n rows inserted below it. Inserting rows only moves
+// the end of ranges that reach beyond the inserted rows.
+func extendRanges(f *excelize.File, sheet string, row, n int) error {
+	// Built-in names like print areas are left as they are
+	for _, name := range f.GetDefinedName() {
+		refersTo := extendRefs(name.RefersTo, sheet, false, row, n)
+		if refersTo == name.RefersTo || strings.HasPrefix(name.Name, "_xlnm.") {
+			continue
+		}
+		if err := f.DeleteDefinedName(&name); err != nil {
+			return err
+		}
+		extended := name
+		extended.RefersTo = refersTo
+		if extended.Scope == "Workbook" {
+			extended.Scope = ""
+		}
+		if err := f.SetDefinedName(&extended); err != nil {
+			return err
+		}
+	}
+
+	for _, formulaSheet := range f.GetSheetList() {
+		if err := extendFormulas(f, formulaSheet, sheet, row, n); err != nil {
+			return err
+		}
+	}
+
+	// A table is replaced by one over the extended range with the same style
+	tables, err := f.GetTables(sheet)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		extended := extendRefs(table.Range, sheet, true, row, n)
+		if extended == table.Range {
+			continue
+		}
+		if err := f.DeleteTable(table.Name); err != nil {
+			return err
+		}
+		if err := f.AddTable(sheet, &excelize.Table{
+			Range:             extended,
+			Name:              table.Name,
+			StyleName:         table.StyleName,
+			ShowColumnStripes: table.ShowColumnStripes,
+			ShowFirstColumn:   table.ShowFirstColumn,
+			ShowLastColumn:    table.ShowLastColumn,
+			ShowRowStripes:    table.ShowRowStripes,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// excelResult is a result sheet listed on the Index sheet
+type excelResult struct {
+	sheet string // first sheet of the result
+	index int
+	rows  int
+	info  QueryInfo
+}
+
+// Names of the generated sheets
+const (
+	indexSheetName   = "Index"
+	runInfoSheetName = "Run info"
+)
+
+// writeInfoSheets adds the Index sheet, which links to every result sheet,
+// as the first sheet of the workbook and the Run info sheet as the last one
+func (w *excelWriter) writeInfoSheets() error {
+	bold, err := w.f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	link, err := w.f.NewStyle(&excelize.Style{Font: &excelize.Font{Color: "0563C1", Underline: "single"}})
+	if err != nil {
+		return err
+	}
+
+	// Index
+	index := w.used.unique(indexSheetName)
+	if _, err := w.f.NewSheet(index); err != nil {
+		return err
+	}
+	header := []interface{}{"#", "Sheet", "Title", "Description", "Rows", "Executed at", "Elapsed", "SQL"}
+	if err := w.f.SetSheetRow(index, "A1", &header); err != nil {
+		return err
+	}
+	if err := w.f.SetCellStyle(index, "A1", "H1", bold); err != nil {
+		return err
+	}
+	for i, r := range w.results {
+		row := i + 2
+		sql := r.info.SQL
+		if sql == "" {
+			sql = r.info.Query
+		}
+		sqlValue, _ := truncateCell(sql)
+		values := []interface{}{
+			r.index, r.sheet, resultHeading(r.info), r.info.Description, r.rows,
+			r.info.Started.Format("2006-01-02 15:04:05"), r.info.Elapsed.Round(time.Millisecond).String(), sqlValue,
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		if err := w.f.SetSheetRow(index, cell, &values); err != nil {
+			return err
+		}
+
+		sheetCell, _ := excelize.CoordinatesToCellName(2, row)
+		location := "'" + strings.ReplaceAll(r.sheet, "'", "''") + "'!A1"
+		if err := w.f.SetCellHyperLink(index, sheetCell, location, "Location"); err != nil {
+			return err
+		}
+		if err := w.f.SetCellStyle(index, sheetCell, sheetCell, link); err != nil {
+			return err
+		}
+	}
+	if err := w.f.SetColWidth(index, "B", "D", 30); err != nil {
+		return err
+	}
+	if err := w.f.SetColWidth(index, "F", "F", 20); err != nil {
+		return err
+	}
+	if err := w.f.SetColWidth(index, "H", "H", 80); err != nil {
+		return err
+	}
+
+	// Run info
+	info := w.used.unique(runInfoSheetName)
+	if _, err := w.f.NewSheet(info); err != nil {
+		return err
+	}
+	for i, values := range runInfoRows(w.config.Run, w.results) {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := w.f.SetSheetRow(info, cell, &values); err != nil {
+			return err
+		}
+		if err := w.f.SetCellStyle(info, cell, cell, bold); err != nil {
+			return err
+		}
+	}
+	if err := w.f.SetColWidth(info, "A", "A", 25); err != nil {
+		return err
+	}
+	if err := w.f.SetColWidth(info, "B", "B", 50); err != nil {
+		return err
+	}
+
+	// Open the workbook on the Index sheet
+	if err := w.f.MoveSheet(index, w.results[0].sheet); err != nil {
+		return err
+	}
+	w.f.SetActiveSheet(0)
+	return nil
+}
+
+// runInfoRows returns the name and value rows of the Run info sheet
+func runInfoRows(run *runInfo, results []excelResult) [][]interface{} {
+	rows := [][]interface{}{{"Generated by", "gocl " + Version}}
+	add := func(name, value string) {
+		if value != "" {
+			rows = append(rows, []interface{}{name, value})
+		}
+	}
+
+	if run != nil {
+		add("Started", run.Started.Format("2006-01-02 15:04:05"))
+		add("Input", run.Input)
+		add("User", run.User)
+		add("Database", run.Database)
+		add("Server", run.Server)
+		add("Container", run.Container)
+		for _, nls := range run.NLS {
+			add("NLS", nls)
+		}
+
+		names := make([]string, 0, len(run.Vars))
+		for name := range run.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rows = append(rows, []interface{}{"Variable " + name, run.Vars[name]})
+		}
+	}
+	add("Finished", time.Now().Format("2006-01-02 15:04:05"))
+
+	total := 0
+	for _, r := range results {
+		total += r.rows
+	}
+	rows = append(rows, []interface{}{"Results", len(results)}, []interface{}{"Rows", total})
+	return rows
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"archive/zip"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestFillTargetExtendsRanges(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetSheetRow("Data", "A4", &[]interface{}{"REGION", "AMOUNT"}); err != nil {
+		t.Fatal(err)
+	}
+	// A one row range on the model row, a total below it and a sum over the
+	// model row and a spare row
+	if err := f.SetDefinedName(&excelize.DefinedName{Name: "dest", RefersTo: "Data!$A$5:$B$5"}); err != nil {
+		t.Fatal(err)
+	}
+	formulas := map[string]string{"B6": "SUM(B5:B5)", "C6": "SUM(B5:B6)", "A1": "COUNTA(Data!A5:A5)"}
+	for cell, formula := range formulas {
+		if err := f.SetCellFormula("Data", cell, formula); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "A1", "SUM(Data!$B$5:$B$5)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddTable("Data", &excelize.Table{Range: "A4:B5", Name: "Sales", StyleName: "TableStyleMedium2"}); err != nil {
+		t.Fatal(err)
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetCellStyle("Data", "B5", "B5", bold); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := resolveTarget(f, "dest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]interface{}{{"EU", 1}, {"US", 2}, {"APAC", 3}}
+	if err := fillTarget(f, target, 2, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range f.GetDefinedName() {
+		if name.Name == "dest" && name.RefersTo != "Data!$A$5:$B$7" {
+			t.Errorf("dest refers to %s, want Data!$A$5:$B$7", name.RefersTo)
+		}
+	}
+	want := []struct{ sheet, cell, formula string }{
+		{"Data", "B8", "SUM(B5:B7)"},
+		{"Data", "C8", "SUM(B5:B8)"},
+		{"Data", "A1", "COUNTA(Data!A5:A7)"},
+		{"Sheet1", "A1", "SUM(Data!$B$5:$B$7)"},
+	}
+	for _, w := range want {
+		if got, _ := f.GetCellFormula(w.sheet, w.cell); got != w.formula {
+			t.Errorf("%s!%s = %s, want %s", w.sheet, w.cell, got, w.formula)
+		}
+	}
+	tables, err := f.GetTables("Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || tables[0].Range != "A4:B7" || tables[0].StyleName != "TableStyleMedium2" {
+		t.Errorf("tables = %+v, want Sales over A4:B7", tables)
+	}
+
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, 5+i)
+		if got, _ := f.GetCellValue("Data", cell); got != row[0] {
+			t.Errorf("%s = %q, want %q", cell, got, row[0])
+		}
+		cell, _ = excelize.CoordinatesToCellName(2, 5+i)
+		if style, _ := f.GetCellStyle("Data", cell); style != bold {
+			t.Errorf("%s has style %d, want the style of the model row", cell, style)
+		}
+	}
+	if _, err := f.WriteToBuffer(); err != nil {
+		t.Errorf("failed to save the filled workbook: %v", err)
+	}
+}
+
+func TestTargetSheetFormats(t *testing.T) {
+	dir := t.TempDir()
+	template := filepath.Join(dir, "template.xlsx")
+	tf := excelize.NewFile()
+	if _, err := tf.NewSheet("Data"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tf.SaveAs(template); err != nil {
+		t.Fatal(err)
+	}
+	tf.Close()
+
+	chart, err := parseChart("line x=DAY y=AMOUNT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	highlight, err := parseHighlight("AMOUNT>1:red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "report.xlsx")
+	w, err := newResultWriter(&OutputConfig{Filename: filename, Format: XLSX, Template: template})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &Result{
+		Columns:    []Column{{Name: "DAY", Type: "NCHAR"}, {Name: "AMOUNT", Type: "NUMBER"}},
+		QueryIndex: 1,
+		Info:       QueryInfo{Target: "Data!B5", Charts: []chartSpec{chart}, Highlights: []highlightSpec{highlight}},
+	}
+	if err := w.BeginResult(res); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range [][]interface{}{{"Mon", int64(1)}, {"Tue", int64(2)}, {"Wed", int64(3)}} {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.EndResult(res); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	formats, err := f.GetConditionalFormats("Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := formats["B6:C8"]; len(rules) != 1 || rules[0].Criteria != "$C6>1" {
+		t.Errorf("conditional formats = %+v, want the highlight over B6:C8", formats)
+	}
+
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	charts := 0
+	for _, file := range archive.File {
+		if filepath.Dir(file.Name) == "xl/charts" {
+			charts++
+		}
+	}
+	if charts != 1 {
+		t.Errorf("got %d charts, want 1", charts)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// XML document layouts
+const (
+	XMLResultSet = "resultset" // <results><resultset name="tab"><row>, NULLs as xsi:nil
+	XMLRowSet    = "rowset"    // DBMS_XMLGEN canonical <ROWSET><ROW>, NULLs left out
+)
+
+// xmlName turns a column name into a valid XML name. Characters that are not
+// allowed are escaped as _xHHHH_ like SQL/XML and DBMS_XMLGEN do.
+func xmlName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		valid := unicode.IsLetter(r) || r == '_' ||
+			(i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.' || unicode.Is(unicode.Mn, r)))
+		switch {
+		case i == 0 && len(runes) >= 3 && strings.EqualFold(string(runes[:3]), "xml"):
+			// Names starting with "xml" are reserved
+			fmt.Fprintf(&b, "_x%04X_", r)
+		case r == '_' && i+1 < len(runes) && runes[i+1] == 'x':
+			// An underscore that would read as the start of an escape
+			b.WriteString("_x005F_")
+		case !valid && r > 0xFFFF:
+			fmt.Fprintf(&b, "_x%08X_", r)
+		case !valid:
+			fmt.Fprintf(&b, "_x%04X_", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// xmlValue returns the XSD lexical form of a value
+func xmlValue(col Column, v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "INF"
+		case math.IsInf(v, -1):
+			return "-INF"
+		}
+		return strconv.FormatFloat(v, 'G', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		// Dates without a time zone are local values
+		if col.IsTime() && !strings.Contains(col.Type, "TZ") {
+			return v.Format("2006-01-02T15:04:05.999999999")
+		}
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return strings.ToUpper(hex.EncodeToString(v))
+	}
+	return formatValue(v)
+}
+
+// oracleNumber writes a number the way Oracle's TO_CHAR does, without the zero before the decimal point
+func oracleNumber(s string) string {
+	if strings.HasPrefix(s, "0.") || strings.HasPrefix(s, "-0.") {
+		return strings.Replace(s, "0.", ".", 1)
+	}
+	return s
+}
+
+// rowSetValue returns a value as DBMS_XMLGEN writes it
+func rowSetValue(col Column, v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return oracleNumber(strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		if col.IsNumeric() {
+			return oracleNumber(v)
+		}
+	case []byte:
+		return strings.ToUpper(hex.EncodeToString(v))
+	}
+	return formatValue(v)
+}
+
+// xmlWriter writes results as XML elements named after their columns
+type xmlWriter struct {
+	out     *outputFile
+	style   string
+	names   []string // element names of the columns
+	columns []Column
+	results int
+}
+
+func newXMLWriter(config *OutputConfig) (*xmlWriter, error) {
+	style := config.XMLStyle
+	if style == "" {
+		style = XMLResultSet
+	}
+	if style != XMLResultSet && style != XMLRowSet {
+		return nil, fmt.Errorf("unknown xml style %q (expected resultset or rowset)", style)
+	}
+
+	out, err := createOutputFile(config)
+	if err != nil {
+		return nil, err
+	}
+	w := &xmlWriter{out: out, style: style}
+	if style == XMLRowSet {
+		fmt.Fprintln(w.out, `<?xml version="1.0"?>`)
+	} else {
+		fmt.Fprintln(w.out, `<?xml version="1.0" encoding="UTF-8"?>`)
+		fmt.Fprintln(w.out, `<results xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">`)
+	}
+	return w, nil
+}
+
+func (w *xmlWriter) BeginResult(res *Result) error {
+	// A DBMS_XMLGEN document is the ROWSET of a single query
+	if w.style == XMLRowSet && w.results > 0 {
+		return fmt.Errorf("an xml rowset document holds a single result, write query %d to its own file", res.QueryIndex)
+	}
+	w.results++
+
+	w.columns = res.Columns
+	w.names = make([]string, len(res.Columns))
+	for i, col := range res.Columns {
+		w.names[i] = xmlName(col.Name)
+	}
+
+	if w.style == XMLRowSet {
+		_, err := fmt.Fprintln(w.out, "<ROWSET>")
+		return err
+	}
+	name := partName(res.Info)
+	if name == "" {
+		name = fmt.Sprintf("Results%d", res.QueryIndex)
+	}
+	_, err := fmt.Fprintf(w.out, "  <resultset name=\"%s\">\n", escapeXMLAttr(name))
+	return err
+}
+
+func (w *xmlWriter) WriteRow(row []interface{}) error {
+	if w.style == XMLRowSet {
+		fmt.Fprintln(w.out, " <ROW>")
+		for i, v := range row {
+			if v == nil {
+				continue
+			}
+			fmt.Fprintf(w.out, "  <%s>", w.names[i])
+			xml.EscapeText(w.out, []byte(rowSetValue(w.columns[i], v)))
+			fmt.Fprintf(w.out, "</%s>\n", w.names[i])
+		}
+		_, err := fmt.Fprintln(w.out, " </ROW>")
+		return err
+	}
+
+	fmt.Fprintln(w.out, "    <row>")
+	for i, v := range row {
+		if v == nil {
+			fmt.Fprintf(w.out, "      <%s xsi:nil=\"true\"/>\n", w.names[i])
+			continue
+		}
+		fmt.Fprintf(w.out, "      <%s>", w.names[i])
+		xml.EscapeText(w.out, []byte(xmlValue(w.columns[i], v)))
+		fmt.Fprintf(w.out, "</%s>\n", w.names[i])
+	}
+	_, err := fmt.Fprintln(w.out, "    </row>")
+	return err
+}
+
+func (w *xmlWriter) EndResult(res *Result) error {
+	if w.style == XMLRowSet {
+		_, err := fmt.Fprintln(w.out, "</ROWSET>")
+		return err
+	}
+	_, err := fmt.Fprintln(w.out, "  </resultset>")
+	return err
+}
+
+func (w *xmlWriter) Size() int64 {
+	return w.out.Size()
+}
+
+func (w *xmlWriter) Close() error {
+	if w.style == XMLResultSet {
+		fmt.Fprintln(w.out, "</results>")
+	} else if w.results == 0 {
+		fmt.Fprintln(w.out, "<ROWSET/>")
+	}
+	return w.out.Close()
+}
+
+func (w *xmlWriter) Abort() {
+	w.out.Abort()
+}